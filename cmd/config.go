@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charlie-haley/omada_exporter/pkg/config"
+	"github.com/urfave/cli/v2"
+)
+
+// loadConfig builds the running config from the config file, if there is one, and the flags.
+// Flags explicitly set on the command line or through environment variables take precedence over the file.
+func loadConfig(c *cli.Context) (*config.Config, error) {
+	conf := flags
+	conf.IncludeSites = c.StringSlice("include-sites")
+	conf.ExcludeSites = c.StringSlice("exclude-sites")
+	conf.ClientLabels = c.StringSlice("clients.labels")
+	conf.ClientAllowMacs = c.StringSlice("clients.allow-macs")
+
+	if conf.ConfigFile != "" {
+		file, err := config.LoadFile(conf.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		err = file.ValidateCollectors(collectorNames)
+		if err != nil {
+			return nil, err
+		}
+		mergeFile(c, &conf, file)
+	}
+
+	// the collector flags take precedence over the collectors in the config file
+	collectors := map[string]bool{}
+	for name, enabled := range conf.Collectors {
+		collectors[name] = enabled
+	}
+	for _, name := range collectorNames {
+		if c.IsSet("collector." + name) {
+			collectors[name] = c.Bool("collector." + name)
+		}
+		if c.IsSet("no-collector."+name) && c.Bool("no-collector."+name) {
+			collectors[name] = false
+		}
+	}
+	conf.Collectors = collectors
+
+	// check if host is properly formatted
+	if strings.HasSuffix(conf.Host, "/") {
+		// remove trailing slash if it exists
+		conf.Host = strings.TrimRight(conf.Host, "/")
+	}
+
+	if conf.ListenAddress == "" || c.IsSet("port") {
+		conf.ListenAddress = fmt.Sprintf(":%s", conf.Port)
+	}
+
+	// checked once merged, as --all-sites can be passed by flag with the include and exclude lists in the file
+	if !conf.AllSites && (len(conf.IncludeSites) > 0 || len(conf.ExcludeSites) > 0) {
+		return nil, fmt.Errorf("the include and exclude sites require --all-sites or sites.all in the config file")
+	}
+
+	if conf.Host == "" {
+		return nil, fmt.Errorf("no controller host configured, set --host or controller.host in the config file")
+	}
+	switch conf.AuthMode {
+	case config.AuthModeWeb:
+		if conf.Username == "" || conf.Password == "" {
+			return nil, fmt.Errorf("no credentials configured, set --username and --password or controller.username and controller.password in the config file")
+		}
+	case config.AuthModeOpenAPI:
+		if conf.ClientId == "" || conf.ClientSecret == "" {
+			return nil, fmt.Errorf("no client credentials configured, set --client-id and --client-secret or controller.client_id and controller.client_secret in the config file")
+		}
+	default:
+		return nil, fmt.Errorf("invalid auth mode %q, must be %q or %q", conf.AuthMode, config.AuthModeWeb, config.AuthModeOpenAPI)
+	}
+
+	if !config.ValidForwardSink(conf.ForwardSink) {
+		return nil, fmt.Errorf("invalid forward sink %q, must be %q, %q or %q", conf.ForwardSink, config.ForwardSinkWebhook, config.ForwardSinkStdout, config.ForwardSinkLoki)
+	}
+	if (conf.ForwardSink == config.ForwardSinkWebhook || conf.ForwardSink == config.ForwardSinkLoki) && conf.ForwardURL == "" {
+		return nil, fmt.Errorf("the %s forward sink requires --forward.url or forward.url in the config file", conf.ForwardSink)
+	}
+	if conf.ForwardInterval <= 0 {
+		return nil, fmt.Errorf("the forward interval must be greater than 0")
+	}
+
+	if !config.ValidClientLabels(conf.ClientLabels) {
+		return nil, fmt.Errorf("invalid client labels %q, must be distinct, include mac and site or site_id, and be any of %s", conf.ClientLabels, strings.Join(config.ClientLabels, ", "))
+	}
+	if conf.ClientMaxSeries < 0 {
+		return nil, fmt.Errorf("the client max series must not be negative")
+	}
+
+	return &conf, nil
+}
+
+func mergeFile(c *cli.Context, conf *config.Config, file *config.File) {
+	if !c.IsSet("host") && file.Controller.Host != "" {
+		conf.Host = file.Controller.Host
+	}
+	if !c.IsSet("auth-mode") && file.Controller.AuthMode != "" {
+		conf.AuthMode = file.Controller.AuthMode
+	}
+	if !c.IsSet("client-id") && file.Controller.ClientId != "" {
+		conf.ClientId = file.Controller.ClientId
+	}
+	if !c.IsSet("client-secret") && file.Controller.ClientSecret != "" {
+		conf.ClientSecret = file.Controller.ClientSecret
+	}
+	if !c.IsSet("username") && file.Controller.Username != "" {
+		conf.Username = file.Controller.Username
+	}
+	if !c.IsSet("password") && file.Controller.Password != "" {
+		conf.Password = file.Controller.Password
+	}
+	if !c.IsSet("insecure") && file.Controller.Insecure {
+		conf.Insecure = true
+	}
+	if !c.IsSet("timeout") && file.Controller.Timeout != 0 {
+		conf.Timeout = file.Controller.Timeout
+	}
+	if !c.IsSet("site") && file.Sites.Name != "" {
+		conf.Site = file.Sites.Name
+	}
+	if !c.IsSet("all-sites") && file.Sites.All {
+		conf.AllSites = true
+	}
+	if !c.IsSet("include-sites") && len(file.Sites.Include) > 0 {
+		conf.IncludeSites = file.Sites.Include
+	}
+	if !c.IsSet("exclude-sites") && len(file.Sites.Exclude) > 0 {
+		conf.ExcludeSites = file.Sites.Exclude
+	}
+	if !c.IsSet("poll-interval") && file.PollInterval != 0 {
+		conf.PollInterval = file.PollInterval
+	}
+	if !c.IsSet("info-metrics") && file.InfoMetrics {
+		conf.InfoMetrics = true
+	}
+	if !c.IsSet("forward.sink") && file.Forward.Sink != "" {
+		conf.ForwardSink = file.Forward.Sink
+	}
+	if !c.IsSet("forward.url") && file.Forward.URL != "" {
+		conf.ForwardURL = file.Forward.URL
+	}
+	if !c.IsSet("forward.interval") && file.Forward.Interval != 0 {
+		conf.ForwardInterval = file.Forward.Interval
+	}
+	if !c.IsSet("forward.cursor-file") && file.Forward.CursorFile != "" {
+		conf.ForwardCursorFile = file.Forward.CursorFile
+	}
+	if !c.IsSet("clients.history") && file.Clients.History {
+		conf.ClientHistory = true
+	}
+	if !c.IsSet("clients.labels") && len(file.Clients.Labels) > 0 {
+		conf.ClientLabels = file.Clients.Labels
+	}
+	if !c.IsSet("clients.max-series") && file.Clients.MaxSeries != 0 {
+		conf.ClientMaxSeries = file.Clients.MaxSeries
+	}
+	if !c.IsSet("clients.aggregate-only") && file.Clients.AggregateOnly {
+		conf.ClientAggregateOnly = true
+	}
+	if !c.IsSet("clients.allow-macs") && len(file.Clients.AllowMacs) > 0 {
+		conf.ClientAllowMacs = file.Clients.AllowMacs
+	}
+	if !c.IsSet("log-level") && file.LogLevel != "" {
+		conf.LogLevel = file.LogLevel
+	}
+	conf.ListenAddress = file.Web.ListenAddress
+	conf.Collectors = file.Collectors
+	conf.Labels = file.Labels
+	conf.Controllers = file.Controllers
+	conf.Modules = file.Modules
+}
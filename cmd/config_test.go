@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charlie-haley/omada_exporter/pkg/config"
+	"github.com/urfave/cli/v2"
+)
+
+// runLoadConfig parses args with the exporter's flags and returns the loaded config
+func runLoadConfig(t *testing.T, args []string) (*config.Config, error) {
+	flags = config.Config{}
+	t.Cleanup(func() { flags = config.Config{} })
+
+	var conf *config.Config
+	var loadErr error
+	app := cli.NewApp()
+	app.Flags = appFlags()
+	app.Action = func(c *cli.Context) error {
+		conf, loadErr = loadConfig(c)
+		return nil
+	}
+	err := app.Run(append([]string{"omada-exporter"}, args...))
+	if err != nil {
+		t.Fatalf("failed to parse flags: %s", err)
+	}
+	return conf, loadErr
+}
+
+func TestLoadConfigSites(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		args []string
+		err  string
+	}{
+		{"all sites flag with include in file", "sites:\n  include: [Default]\n", []string{"--all-sites"}, ""},
+		{"all sites in file with include flag", "sites:\n  all: true\n", []string{"--include-sites", "Default"}, ""},
+		{"include in file without all sites", "sites:\n  include: [Default]\n", nil, "require --all-sites"},
+		{"exclude flag without all sites", "", []string{"--exclude-sites", "Default"}, "require --all-sites"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			err := os.WriteFile(path, []byte("controller:\n  host: https://omada\n  username: user\n  password: password\n"+tt.file), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			conf, err := runLoadConfig(t, append([]string{"--config.file", path}, tt.args...))
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if !conf.AllSites {
+					t.Error("expected all sites to be enabled")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected an error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigMerge(t *testing.T) {
+	file := `
+log_level: info
+controller:
+  host: https://file
+  username: file-user
+  password: file-password
+  timeout: 30
+sites:
+  name: File
+clients:
+  labels: [mac, site]
+collectors:
+  port: false
+  events: false
+`
+
+	tests := []struct {
+		name  string
+		args  []string
+		env   map[string]string
+		check func(conf *config.Config) string
+	}{
+		{"file", nil, nil, func(conf *config.Config) string {
+			if conf.Host != "https://file" || conf.Username != "file-user" || conf.Timeout != 30 || conf.Site != "File" {
+				return "expected the values from the file"
+			}
+			return ""
+		}},
+		{"flags take precedence", []string{"--host", "https://flag/", "--timeout", "5", "--site", "Flag"}, nil, func(conf *config.Config) string {
+			if conf.Host != "https://flag" || conf.Timeout != 5 || conf.Site != "Flag" || conf.Username != "file-user" {
+				return "expected the flags over the file, and the file for the rest"
+			}
+			return ""
+		}},
+		{"environment takes precedence", nil, map[string]string{"OMADA_USER": "env-user"}, func(conf *config.Config) string {
+			if conf.Username != "env-user" || conf.Password != "file-password" {
+				return "expected the environment over the file"
+			}
+			return ""
+		}},
+		{"defaults don't override the file", []string{"--port", "9300"}, nil, func(conf *config.Config) string {
+			if conf.Timeout != 30 || conf.LogLevel != "info" || conf.ListenAddress != ":9300" {
+				return "expected unset flags not to replace the file"
+			}
+			return ""
+		}},
+		{"slice flags take precedence", []string{"--clients.labels", "mac", "--clients.labels", "site_id"}, nil, func(conf *config.Config) string {
+			if strings.Join(conf.ClientLabels, ",") != "mac,site_id" {
+				return "expected the labels from the flags"
+			}
+			return ""
+		}},
+		{"collector flags take precedence", []string{"--collector.port", "--no-collector.client"}, nil, func(conf *config.Config) string {
+			if !conf.Collectors["port"] || conf.Collectors["client"] || conf.Collectors["events"] {
+				return "expected the collector flags over the file"
+			}
+			return ""
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			err := os.WriteFile(path, []byte(file), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			conf, err := runLoadConfig(t, append([]string{"--config.file", path}, tt.args...))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if msg := tt.check(conf); msg != "" {
+				t.Errorf("%s, got %+v", msg, conf)
+			}
+		})
+	}
+}
@@ -1,14 +1,20 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/charlie-haley/omada_exporter/pkg/api"
 	"github.com/charlie-haley/omada_exporter/pkg/collector"
 	"github.com/charlie-haley/omada_exporter/pkg/config"
+	"github.com/charlie-haley/omada_exporter/pkg/forward"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	zerolog "github.com/rs/zerolog"
@@ -18,7 +24,7 @@ import (
 
 var version = "development"
 
-var conf = config.Config{}
+var flags = config.Config{}
 
 func Run() {
 	app := cli.NewApp()
@@ -29,18 +35,7 @@ func Run() {
 	app.Authors = []*cli.Author{
 		{Name: "Charlie Haley", Email: "charlie-haley@users.noreply.github.com"},
 	}
-	app.Flags = []cli.Flag{
-		&cli.StringFlag{Destination: &conf.Host, Required: true, Name: "host", Value: "", Usage: "The hostname of the Omada Controller, including protocol.", EnvVars: []string{"OMADA_HOST"}},
-		&cli.StringFlag{Destination: &conf.Username, Required: true, Name: "username", Value: "", Usage: "Username of the Omada user you'd like to use to fetch metrics.", EnvVars: []string{"OMADA_USER"}},
-		&cli.StringFlag{Destination: &conf.Password, Required: true, Name: "password", Value: "", Usage: "Password for your Omada user.", EnvVars: []string{"OMADA_PASS"}},
-		&cli.StringFlag{Destination: &conf.Port, Name: "port", Value: "9202", Usage: "Port on which to expose the Prometheus metrics.", EnvVars: []string{"OMADA_PORT"}},
-		&cli.StringFlag{Destination: &conf.Site, Name: "site", Value: "Default", Usage: "Omada site to scrape metrics from.", EnvVars: []string{"OMADA_SITE"}},
-		&cli.StringFlag{Destination: &conf.LogLevel, Name: "log-level", Value: "error", Usage: "Application log level.", EnvVars: []string{"LOG_LEVEL"}},
-		&cli.IntFlag{Destination: &conf.Timeout, Name: "timeout", Value: 15, Usage: "Timeout when making requests to the Omada Controller.", EnvVars: []string{"OMADA_REQUEST_TIMEOUT"}},
-		&cli.BoolFlag{Destination: &conf.Insecure, Name: "insecure", Value: false, Usage: "Whether to skip verifying the SSL certificate on the controller.", EnvVars: []string{"OMADA_INSECURE"}},
-		&cli.BoolFlag{Destination: &conf.GoCollectorDisabled, Name: "disable-go-collector", Value: true, Usage: "Disable Go collector metrics.", EnvVars: []string{"OMADA_DISABLE_GO_COLLECTOR"}},
-		&cli.BoolFlag{Destination: &conf.ProcessCollectorDisabled, Name: "disable-process-collector", Value: true, Usage: "Disable process collector metrics.", EnvVars: []string{"OMADA_DISABLE_PROCESS_COLLECTOR"}},
-	}
+	app.Flags = appFlags()
 	app.Commands = []*cli.Command{
 		{Name: "version", Aliases: []string{"v"}, Usage: "prints the current version.",
 			Action: func(c *cli.Context) error {
@@ -48,6 +43,11 @@ func Run() {
 				os.Exit(0)
 				return nil
 			}},
+		{Name: "topology", Usage: "prints the devices of each site as a tree of their uplinks.",
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "format", Value: "text", Usage: "Output format, either \"text\" or \"dot\" for Graphviz."},
+			},
+			Action: topology},
 		{Name: "mdocs", Aliases: []string{"md"}, Usage: "prints the metric docs.",
 			Action: func(c *cli.Context) error {
 				mdocs()
@@ -64,41 +64,73 @@ func Run() {
 	}
 }
 
-func run(c *cli.Context) error {
-	// set log level
-	level, err := zerolog.ParseLevel(conf.LogLevel)
-	if err != nil {
-		return err
-	}
-	zerolog.SetGlobalLevel(level)
-
-	if conf.GoCollectorDisabled {
-		// remove Go collector
-		prometheus.Unregister(prometheus.NewGoCollector())
-	}
-
-	if conf.ProcessCollectorDisabled {
-		// remove Process collector
-		prometheus.Unregister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
-	}
+// appFlags returns the flags of the exporter, which write to the global flags config
+func appFlags() []cli.Flag {
+	return append([]cli.Flag{
+		&cli.StringFlag{Destination: &flags.Host, Name: "host", Value: "", Usage: "The hostname of the Omada Controller, including protocol.", EnvVars: []string{"OMADA_HOST"}},
+		&cli.StringFlag{Destination: &flags.AuthMode, Name: "auth-mode", Value: config.AuthModeWeb, Usage: "How to authenticate with the controller, either \"web\" with a username and password or \"openapi\" with client credentials.", EnvVars: []string{"OMADA_AUTH_MODE"}},
+		&cli.StringFlag{Destination: &flags.Username, Name: "username", Value: "", Usage: "Username of the Omada user you'd like to use to fetch metrics.", EnvVars: []string{"OMADA_USER"}},
+		&cli.StringFlag{Destination: &flags.Password, Name: "password", Value: "", Usage: "Password for your Omada user.", EnvVars: []string{"OMADA_PASS"}},
+		&cli.StringFlag{Destination: &flags.ClientId, Name: "client-id", Value: "", Usage: "Client ID of the OpenAPI application, used with --auth-mode openapi.", EnvVars: []string{"OMADA_CLIENT_ID"}},
+		&cli.StringFlag{Destination: &flags.ClientSecret, Name: "client-secret", Value: "", Usage: "Client secret of the OpenAPI application, used with --auth-mode openapi.", EnvVars: []string{"OMADA_CLIENT_SECRET"}},
+		&cli.StringFlag{Destination: &flags.Port, Name: "port", Value: "9202", Usage: "Port on which to expose the Prometheus metrics.", EnvVars: []string{"OMADA_PORT"}},
+		&cli.StringFlag{Destination: &flags.Site, Name: "site", Value: "Default", Usage: "Omada site to scrape metrics from.", EnvVars: []string{"OMADA_SITE"}},
+		&cli.BoolFlag{Destination: &flags.AllSites, Name: "all-sites", Value: false, Usage: "Scrape metrics from every site the Omada user can see, ignoring --site.", EnvVars: []string{"OMADA_ALL_SITES"}},
+		&cli.StringSliceFlag{Name: "include-sites", Usage: "Only scrape these site names when --all-sites is set.", EnvVars: []string{"OMADA_INCLUDE_SITES"}},
+		&cli.StringSliceFlag{Name: "exclude-sites", Usage: "Skip these site names when --all-sites is set.", EnvVars: []string{"OMADA_EXCLUDE_SITES"}},
+		&cli.StringFlag{Destination: &flags.ConfigFile, Name: "config.file", Value: "", Usage: "Path to a YAML or TOML config file, flags take precedence over values in the file.", EnvVars: []string{"OMADA_CONFIG_FILE"}},
+		&cli.StringFlag{Destination: &flags.LogLevel, Name: "log-level", Value: "error", Usage: "Application log level.", EnvVars: []string{"LOG_LEVEL"}},
+		&cli.IntFlag{Destination: &flags.Timeout, Name: "timeout", Value: 15, Usage: "Timeout when making requests to the Omada Controller.", EnvVars: []string{"OMADA_REQUEST_TIMEOUT"}},
+		&cli.BoolFlag{Destination: &flags.Insecure, Name: "insecure", Value: false, Usage: "Whether to skip verifying the SSL certificate on the controller.", EnvVars: []string{"OMADA_INSECURE"}},
+		&cli.DurationFlag{Destination: &flags.PollInterval, Name: "poll-interval", Value: 0, Usage: "Poll the controller in the background on this interval and serve /metrics from the last poll, instead of calling the controller on every scrape. Disabled when 0.", EnvVars: []string{"OMADA_POLL_INTERVAL"}},
+		&cli.BoolFlag{Destination: &flags.InfoMetrics, Name: "info-metrics", Value: false, Usage: "Only label the device, controller, port and client metrics with the mac, site_id and port, and move their other labels to omada_*_info metrics.", EnvVars: []string{"OMADA_INFO_METRICS"}},
+		&cli.BoolFlag{Destination: &flags.ClientHistory, Name: "clients.history", Value: false, Usage: "Also fetch each site's past client connections, to count the distinct clients and the sessions ended each day.", EnvVars: []string{"OMADA_CLIENTS_HISTORY"}},
+		&cli.StringSliceFlag{Name: "clients.labels", Usage: "Only add these labels to the per-client metrics, mac and site or site_id are required. Defaults to every label.", EnvVars: []string{"OMADA_CLIENTS_LABELS"}},
+		&cli.IntFlag{Destination: &flags.ClientMaxSeries, Name: "clients.max-series", Value: 0, Usage: "The most clients of each site to report per-client metrics for, the rest are counted in omada_client_series_overflow_total. Unlimited when 0.", EnvVars: []string{"OMADA_CLIENTS_MAX_SERIES"}},
+		&cli.BoolFlag{Destination: &flags.ClientAggregateOnly, Name: "clients.aggregate-only", Value: false, Usage: "Report the clients of each AP, SSID and VLAN instead of per-client metrics, except for --clients.allow-macs.", EnvVars: []string{"OMADA_CLIENTS_AGGREGATE_ONLY"}},
+		&cli.StringSliceFlag{Name: "clients.allow-macs", Usage: "MAC addresses of clients which always get per-client metrics, even with --clients.aggregate-only or over --clients.max-series.", EnvVars: []string{"OMADA_CLIENTS_ALLOW_MACS"}},
+		&cli.StringFlag{Destination: &flags.ForwardSink, Name: "forward.sink", Value: "", Usage: "Forward the controller's alerts and events to \"webhook\", \"stdout\" or \"loki\". Disabled when empty.", EnvVars: []string{"OMADA_FORWARD_SINK"}},
+		&cli.StringFlag{Destination: &flags.ForwardURL, Name: "forward.url", Value: "", Usage: "URL of the webhook, or of the Loki push API, e.g. http://loki:3100/loki/api/v1/push.", EnvVars: []string{"OMADA_FORWARD_URL"}},
+		&cli.DurationFlag{Destination: &flags.ForwardInterval, Name: "forward.interval", Value: 30 * time.Second, Usage: "How often to check the controller for new alerts and events to forward.", EnvVars: []string{"OMADA_FORWARD_INTERVAL"}},
+		&cli.StringFlag{Destination: &flags.ForwardCursorFile, Name: "forward.cursor-file", Value: "", Usage: "File to store the last forwarded alert and event in, so they aren't forwarded again after a restart.", EnvVars: []string{"OMADA_FORWARD_CURSOR_FILE"}},
+		&cli.BoolFlag{Destination: &flags.GoCollectorDisabled, Name: "disable-go-collector", Value: true, Usage: "Disable Go collector metrics.", EnvVars: []string{"OMADA_DISABLE_GO_COLLECTOR"}},
+		&cli.BoolFlag{Destination: &flags.ProcessCollectorDisabled, Name: "disable-process-collector", Value: true, Usage: "Disable process collector metrics.", EnvVars: []string{"OMADA_DISABLE_PROCESS_COLLECTOR"}},
+	}, collectorFlags()...)
+}
 
-	// check if host is properly formatted
-	if strings.HasSuffix(conf.Host, "/") {
-		// remove trailing slash if it exists
-		conf.Host = strings.TrimRight(conf.Host, "/")
+func run(c *cli.Context) error {
+	e := &exporter{roams: collector.NewRoamTracker()}
+	err := e.reload(c)
+	var unreachable *unreachableError
+	if errors.As(err, &unreachable) {
+		// serve omada_up 0 while the controller is down, rather than exiting, and keep trying to connect
+		log.Error().Err(err).Msg(fmt.Sprintf("failed to connect to the controller, retrying every %s", controllerRetryInterval))
+		err = e.startUnreachable(c)
+		if err == nil {
+			go e.retry(c)
+		}
 	}
-
-	client, err := api.Configure(&conf)
 	if err != nil {
 		return err
 	}
 
-	// register omada collectors
-	for _, c := range collectors(client) {
-		prometheus.MustRegister(c)
-	}
+	// reload the config file on SIGHUP without restarting the HTTP server
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Info().Msg("received SIGHUP, reloading config")
+			err := e.reload(c)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to reload config, keeping the previous config")
+				continue
+			}
+			log.Info().Msg("config reloaded")
+		}
+	}()
 
-	log.Info().Msg(fmt.Sprintf("listening on :%s", conf.Port))
+	listenAddress := e.config().ListenAddress
+	log.Info().Msg(fmt.Sprintf("listening on %s", listenAddress))
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		_, _ = w.Write([]byte(`<html>
     <head>
@@ -113,15 +145,246 @@ func run(c *cli.Context) error {
     </html>`))
 	})
 
-	http.Handle("/metrics", promhttp.Handler())
-	err = http.ListenAndServe(fmt.Sprintf(":%s", conf.Port), nil)
+	http.Handle("/metrics", e)
+	http.HandleFunc("/probe", func(w http.ResponseWriter, r *http.Request) {
+		e.prober().ServeHTTP(w, r)
+	})
+	err = http.ListenAndServe(listenAddress, nil)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// how often to retry connecting to a controller that couldn't be reached when the exporter started
+const controllerRetryInterval = 30 * time.Second
+
+// unreachableError is returned by reload when the config is valid but the controller couldn't be connected to
+type unreachableError struct {
+	err error
+}
+
+func (e *unreachableError) Error() string {
+	return fmt.Sprintf("failed to connect to the controller: %s", e.err)
+}
+
+func (e *unreachableError) Unwrap() error {
+	return e.err
+}
+
+// exporter holds the state built from the running config, which is swapped out on reload
+type exporter struct {
+	// reloadMu serialises reloads, from SIGHUP and from retrying an unreachable controller
+	reloadMu   sync.Mutex
+	mu         sync.RWMutex
+	conf       *config.Config
+	client     *api.Client
+	collectors map[string]collector.Collector
+	registry   *prometheus.Registry
+	omada      prometheus.Gatherer
+	poller     *poller
+	forwarder  *forward.Forwarder
+	probe      *probeHandler
+	// roams is kept across reloads, so the roam counters don't reset
+	roams *collector.RoamTracker
+}
+
+// reload builds a new config, client and registry, only replacing the running ones if all succeed
+func (e *exporter) reload(c *cli.Context) error {
+	e.reloadMu.Lock()
+	defer e.reloadMu.Unlock()
+
+	conf, err := loadConfig(c)
+	if err != nil {
+		return err
+	}
+
+	// set log level
+	level, err := zerolog.ParseLevel(conf.LogLevel)
+	if err != nil {
+		return err
+	}
+	zerolog.SetGlobalLevel(level)
+
+	client, err := api.Configure(conf)
+	if err != nil {
+		return &unreachableError{err}
+	}
+
+	registry := newRegistry(conf)
+
+	// register omada collectors, separately to the process metrics so they can be polled in the background
+	omadaRegistry := prometheus.NewRegistry()
+	enabled := enabledCollectors(client, conf, e.roams)
+	err = registerCollectors(omadaRegistry, client, conf, enabled)
+	if err != nil {
+		return err
+	}
+
+	var f *forward.Forwarder
+	if conf.ForwardSink != "" {
+		sink, err := forward.NewSink(conf.ForwardSink, conf.ForwardURL, time.Duration(conf.Timeout)*time.Second)
+		if err != nil {
+			return err
+		}
+		f, err = forward.NewForwarder(client, sink, conf.ForwardInterval, conf.ForwardCursorFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	var omada prometheus.Gatherer = omadaRegistry
+	var p *poller
+	if conf.PollInterval > 0 {
+		p = newPoller(client, omadaRegistry, conf.PollInterval)
+		err = prometheus.WrapRegistererWith(conf.Labels, registry).Register(p)
+		if err != nil {
+			return err
+		}
+		p.start()
+		omada = p
+	}
+
+	e.mu.Lock()
+	if e.conf != nil && e.conf.ListenAddress != conf.ListenAddress {
+		log.Warn().Msg("the listen address can't be changed on reload, restart the exporter to apply it")
+	}
+	if e.poller != nil {
+		e.poller.close()
+	}
+	previous := e.forwarder
+	e.conf = conf
+	e.client = client
+	e.collectors = enabled
+	e.registry = registry
+	e.omada = omada
+	e.poller = p
+	e.forwarder = f
+	e.probe = newProbeHandler(conf)
+	e.mu.Unlock()
+
+	// closing the previous forwarder waits for its poll to finish, which mustn't block scrapes, and it's closed
+	// before the new one starts so they don't both forward the same entries
+	if previous != nil {
+		previous.Close()
+	}
+	if f != nil {
+		f.Start()
+	}
+
+	return nil
+}
+
+// startUnreachable serves omada_up 0 in place of the omada collectors, until the controller can be connected to
+func (e *exporter) startUnreachable(c *cli.Context) error {
+	conf, err := loadConfig(c)
+	if err != nil {
+		return err
+	}
+
+	omadaRegistry := prometheus.NewRegistry()
+	err = prometheus.WrapRegistererWith(conf.Labels, omadaRegistry).Register(collector.NewUnreachableCollector())
 	if err != nil {
 		return err
 	}
 
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.conf = conf
+	e.collectors = map[string]collector.Collector{}
+	e.registry = newRegistry(conf)
+	e.omada = omadaRegistry
+	e.probe = newProbeHandler(conf)
 	return nil
 }
 
+// retry reloads the config until the controller can be connected to, or a reload on SIGHUP has connected to it
+func (e *exporter) retry(c *cli.Context) {
+	ticker := time.NewTicker(controllerRetryInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		e.mu.RLock()
+		connected := e.client != nil
+		e.mu.RUnlock()
+		if connected {
+			return
+		}
+
+		err := e.reload(c)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to connect to the controller, retrying")
+			continue
+		}
+		log.Info().Msg("connected to the controller")
+		return
+	}
+}
+
+// newRegistry returns a registry with the Go and process collectors, unless they're disabled
+func newRegistry(conf *config.Config) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	if !conf.GoCollectorDisabled {
+		registry.MustRegister(prometheus.NewGoCollector())
+	}
+	if !conf.ProcessCollectorDisabled {
+		registry.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	}
+	return registry
+}
+
+func (e *exporter) config() *config.Config {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.conf
+}
+
+func (e *exporter) prober() *probeHandler {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.probe
+}
+
+func (e *exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.RLock()
+	conf := e.conf
+	client := e.client
+	enabled := e.collectors
+	registry := e.registry
+	omada := e.omada
+	polling := e.poller != nil
+	e.mu.RUnlock()
+
+	// collect[] limits the scrape to the given collectors, so expensive collectors can be scraped by a separate job
+	filters := r.URL.Query()["collect[]"]
+	if len(filters) > 0 {
+		if polling {
+			http.Error(w, "collect[] is not supported when polling, every collector is served from the last poll", http.StatusBadRequest)
+			return
+		}
+
+		filtered := map[string]collector.Collector{}
+		for _, name := range filters {
+			c, ok := enabled[name]
+			if !ok {
+				http.Error(w, fmt.Sprintf("collector %q is unknown or disabled", name), http.StatusBadRequest)
+				return
+			}
+			filtered[name] = c
+		}
+
+		filteredRegistry := prometheus.NewRegistry()
+		err := registerCollectors(filteredRegistry, client, conf, filtered)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		omada = filteredRegistry
+	}
+
+	promhttp.HandlerFor(prometheus.Gatherers{registry, omada}, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
 // mdocs just spits out the metrics descriptions and exits
 func mdocs() {
 
@@ -129,9 +392,12 @@ func mdocs() {
 	dc := make(chan *prometheus.Desc)
 	go func() {
 		// collectors can't Collect without a client, but Describe doesn't need one.
-		for _, c := range collectors(nil) {
-			c.Describe(dc)
+		collector.NewOmadaCollector(nil).Describe(dc)
+		all := collectors(nil, nil)
+		for _, name := range collectorNames {
+			all[name].Describe(dc)
 		}
+		api.NewMetrics().Describe(dc)
 		close(dc)
 	}()
 
@@ -153,12 +419,66 @@ func mdocs() {
 	}
 }
 
-// collectors returns the full complement of configured collectors.
-func collectors(client *api.Client) []prometheus.Collector {
-	return []prometheus.Collector{
-		collector.NewClientCollector(client),
-		collector.NewControllerCollector(client),
-		collector.NewDeviceCollector(client),
-		collector.NewPortCollector(client),
+var collectorNames = []string{"ap", "client", "controller", "device", "events", "gateway", "port", "ssid", "topology"}
+
+// defaultDisabledCollectors make extra requests to the controller on every scrape, so they're only enabled by flag or config file
+var defaultDisabledCollectors = map[string]bool{"ap": true, "events": true, "gateway": true, "ssid": true, "topology": true}
+
+// collectors returns the full complement of collectors, keyed by name.
+func collectors(client *api.Client, roams *collector.RoamTracker) map[string]collector.Collector {
+	return map[string]collector.Collector{
+		"ap":         collector.NewApCollector(client),
+		"client":     collector.NewClientCollector(client, roams),
+		"controller": collector.NewControllerCollector(client),
+		"device":     collector.NewDeviceCollector(client),
+		"events":     collector.NewEventsCollector(client),
+		"gateway":    collector.NewGatewayCollector(client),
+		"port":       collector.NewPortCollector(client),
+		"ssid":       collector.NewSsidCollector(client),
+		"topology":   collector.NewTopologyCollector(client),
+	}
+}
+
+// enabledCollectors returns the collectors enabled in the config, keyed by name.
+func enabledCollectors(client *api.Client, conf *config.Config, roams *collector.RoamTracker) map[string]collector.Collector {
+	all := collectors(client, roams)
+	enabled := map[string]collector.Collector{}
+	for _, name := range collectorNames {
+		e, ok := conf.Collectors[name]
+		if !ok {
+			e = !defaultDisabledCollectors[name]
+		}
+		if !e {
+			continue
+		}
+		enabled[name] = all[name]
+	}
+	return enabled
+}
+
+// registerCollectors registers the collectors and the client's API metrics, adding the configured constant labels.
+func registerCollectors(registry prometheus.Registerer, client *api.Client, conf *config.Config, enabled map[string]collector.Collector) error {
+	registerer := prometheus.WrapRegistererWith(conf.Labels, registry)
+	err := registerer.Register(collector.NewOmadaCollector(enabled))
+	if err != nil {
+		return fmt.Errorf("failed to register collectors: %s", err)
+	}
+	err = registerer.Register(client.Metrics)
+	if err != nil {
+		return fmt.Errorf("failed to register API metrics: %s", err)
+	}
+	return nil
+}
+
+// collectorFlags returns node_exporter style flags to enable and disable each collector.
+func collectorFlags() []cli.Flag {
+	collectorFlags := []cli.Flag{}
+	for _, name := range collectorNames {
+		env := strings.ToUpper(name)
+		collectorFlags = append(collectorFlags,
+			&cli.BoolFlag{Name: "collector." + name, Value: !defaultDisabledCollectors[name], Usage: fmt.Sprintf("Enable the %s collector.", name), EnvVars: []string{"OMADA_COLLECTOR_" + env}},
+			&cli.BoolFlag{Name: "no-collector." + name, Value: false, Usage: fmt.Sprintf("Disable the %s collector.", name), EnvVars: []string{"OMADA_NO_COLLECTOR_" + env}},
+		)
 	}
+	return collectorFlags
 }
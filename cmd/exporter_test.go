@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/charlie-haley/omada_exporter/pkg/api"
+	"github.com/charlie-haley/omada_exporter/pkg/collector"
+	"github.com/charlie-haley/omada_exporter/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// stubCollector reports a single metric without calling the controller
+type stubCollector struct {
+	desc *prometheus.Desc
+}
+
+func (s stubCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.desc
+}
+
+func (s stubCollector) Update(ch chan<- prometheus.Metric) error {
+	ch <- prometheus.MustNewConstMetric(s.desc, prometheus.GaugeValue, 1)
+	return nil
+}
+
+func TestExporterCollectFilter(t *testing.T) {
+	conf := &config.Config{Labels: map[string]string{"controller": "main"}}
+	client := &api.Client{Config: conf, Metrics: api.NewMetrics()}
+	enabled := map[string]collector.Collector{
+		"device": stubCollector{prometheus.NewDesc("omada_stub_device", "", nil, nil)},
+		"client": stubCollector{prometheus.NewDesc("omada_stub_client", "", nil, nil)},
+	}
+	omada := prometheus.NewRegistry()
+	err := registerCollectors(omada, client, conf, enabled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &exporter{conf: conf, client: client, collectors: enabled, registry: prometheus.NewRegistry(), omada: omada}
+
+	tests := []struct {
+		name     string
+		query    string
+		status   int
+		included []string
+		excluded []string
+	}{
+		{"every collector", "", http.StatusOK, []string{"omada_stub_device", "omada_stub_client", `omada_api_login_attempts_total{controller="main"}`}, nil},
+		{"one collector", "?collect[]=device", http.StatusOK, []string{"omada_stub_device", `omada_api_login_attempts_total{controller="main"}`}, []string{"omada_stub_client"}},
+		{"unknown collector", "?collect[]=other", http.StatusBadRequest, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, httptest.NewRequest("GET", "/metrics"+tt.query, nil))
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+			}
+			for _, s := range tt.included {
+				if !strings.Contains(w.Body.String(), s) {
+					t.Errorf("expected %s in\n%s", s, w.Body.String())
+				}
+			}
+			for _, s := range tt.excluded {
+				if strings.Contains(w.Body.String(), s) {
+					t.Errorf("expected no %s in\n%s", s, w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestEnabledCollectors(t *testing.T) {
+	tests := []struct {
+		name       string
+		collectors map[string]bool
+		enabled    []string
+		disabled   []string
+	}{
+		{"defaults", nil, []string{"client", "device", "port"}, []string{"ap", "events", "gateway", "ssid", "topology"}},
+		{"enabled by config", map[string]bool{"gateway": true}, []string{"client", "gateway"}, nil},
+		{"disabled by config", map[string]bool{"device": false}, []string{"client"}, []string{"ap", "device", "gateway"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enabled := enabledCollectors(nil, &config.Config{Collectors: tt.collectors}, nil)
+			for _, name := range tt.enabled {
+				if _, ok := enabled[name]; !ok {
+					t.Errorf("expected %s to be enabled", name)
+				}
+			}
+			for _, name := range tt.disabled {
+				if _, ok := enabled[name]; ok {
+					t.Errorf("expected %s to be disabled", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"sync"
+	"time"
+
+	"github.com/charlie-haley/omada_exporter/pkg/api"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/rs/zerolog/log"
+)
+
+// poller scrapes the omada collectors in the background on an interval, /metrics then serves
+// the metrics from the last poll instead of calling the controller on every scrape
+type poller struct {
+	omadaSnapshotAgeSeconds *prometheus.Desc
+	client                  *api.Client
+	registry                *prometheus.Registry
+	interval                time.Duration
+	stop                    chan struct{}
+	mu                      sync.RWMutex
+	families                []*dto.MetricFamily
+	lastPoll                time.Time
+}
+
+func newPoller(client *api.Client, registry *prometheus.Registry, interval time.Duration) *poller {
+	return &poller{
+		omadaSnapshotAgeSeconds: prometheus.NewDesc("omada_snapshot_age_seconds",
+			"Seconds since the metrics were last polled from the controller.",
+			nil,
+			nil,
+		),
+		client:   client,
+		registry: registry,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+// start polls once, so there are metrics to serve straight away, then keeps polling in the background until stopped
+func (p *poller) start() {
+	p.poll()
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.poll()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (p *poller) close() {
+	close(p.stop)
+}
+
+func (p *poller) poll() {
+	// every request made while gathering is memoized, so collectors sharing an endpoint read the same response
+	p.client.BeginSnapshot()
+	families, err := p.registry.Gather()
+	p.client.EndSnapshot()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to gather metrics while polling")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.families = families
+	p.lastPoll = time.Now()
+}
+
+// Gather returns the metrics from the last poll
+func (p *poller) Gather() ([]*dto.MetricFamily, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.families, nil
+}
+
+func (p *poller) Describe(ch chan<- *prometheus.Desc) {
+	ch <- p.omadaSnapshotAgeSeconds
+}
+
+func (p *poller) Collect(ch chan<- prometheus.Metric) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.lastPoll.IsZero() {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(p.omadaSnapshotAgeSeconds, prometheus.GaugeValue, time.Since(p.lastPoll).Seconds())
+}
@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charlie-haley/omada_exporter/pkg/api"
+	"github.com/charlie-haley/omada_exporter/pkg/collector"
+	"github.com/charlie-haley/omada_exporter/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/rs/zerolog/log"
+)
+
+// logged in clients unused for probeClientTTL are dropped, and at most probeMaxClients are kept,
+// so that probing many distinct targets doesn't keep a client for each of them forever
+const (
+	probeClientTTL  = 15 * time.Minute
+	probeMaxClients = 64
+)
+
+// probeHandler serves /probe, scraping the controller passed in the target parameter
+// using the credentials from the named module in the config file
+type probeHandler struct {
+	conf    *config.Config
+	modules map[string]config.Module
+	mu      sync.Mutex
+	clients map[string]*probeClient
+}
+
+// probeClient is a logged in client for a target, with collectors that are kept
+// between probes as some, like the events collector, count changes between scrapes.
+// It's cached before logging in, and once ensures only the first probe of a target logs in.
+type probeClient struct {
+	once       sync.Once
+	client     *api.Client
+	collectors map[string]collector.Collector
+	err        error
+	lastUsed   time.Time
+}
+
+func newProbeHandler(conf *config.Config) *probeHandler {
+	// the default module falls back to the credentials passed by flag
+	modules := map[string]config.Module{
+		"default": {
+			AuthMode:     conf.AuthMode,
+			Username:     conf.Username,
+			Password:     conf.Password,
+			ClientId:     conf.ClientId,
+			ClientSecret: conf.ClientSecret,
+			Insecure:     conf.Insecure,
+			Timeout:      conf.Timeout,
+		},
+	}
+	for name, m := range conf.Modules {
+		modules[name] = m
+	}
+
+	return &probeHandler{
+		conf:    conf,
+		modules: modules,
+		clients: map[string]*probeClient{},
+	}
+}
+
+func (h *probeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	target := strings.TrimRight(params.Get("target"), "/")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+
+	moduleName := params.Get("module")
+	site := params.Get("site")
+
+	// the target can also be the name of a controller from the config file
+	controller, named := h.conf.Controllers[target]
+	if named {
+		target = strings.TrimRight(controller.Host, "/")
+		if moduleName == "" {
+			moduleName = controller.Module
+		}
+		if site == "" {
+			site = controller.Site
+		}
+	}
+
+	if moduleName == "" {
+		moduleName = "default"
+	}
+	module, ok := h.modules[moduleName]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown module %q", moduleName), http.StatusBadRequest)
+		return
+	}
+	// credentials must only be sent to the configured controllers, or the targets the module lists
+	configured := h.configured(target)
+	if !configured && !moduleTarget(module, target) {
+		http.Error(w, fmt.Sprintf("module %q can only probe --host, a controller from the config file or its targets, not %s", moduleName, target), http.StatusBadRequest)
+		return
+	}
+
+	if site == "" {
+		site = h.conf.Site
+	}
+
+	registry := prometheus.NewRegistry()
+	probe, err := h.getClient(target, site, moduleName, module, configured)
+	if err != nil {
+		// like the blackbox exporter, a controller that can't be reached is reported by omada_up rather than the status code
+		log.Error().Err(err).Str("target", target).Msg("Failed to configure client for probe")
+		err = prometheus.WrapRegistererWith(h.conf.Labels, registry).Register(collector.NewUnreachableCollector())
+	} else {
+		err = registerCollectors(registry, probe.client, h.conf, probe.collectors)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// configured returns whether the target is --host or the host of a controller from the config file
+func (h *probeHandler) configured(target string) bool {
+	if target == strings.TrimRight(h.conf.Host, "/") {
+		return true
+	}
+	for _, controller := range h.conf.Controllers {
+		if target == strings.TrimRight(controller.Host, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// moduleTarget returns whether the target is one of the module's targets
+func moduleTarget(module config.Module, target string) bool {
+	for _, t := range module.Targets {
+		if target == strings.TrimRight(t, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// getClient returns the cached client for a target, creating and logging in a new one if needed.
+// Logging in happens outside the lock, so a slow controller doesn't hold up probes of the others.
+func (h *probeHandler) getClient(target string, site string, moduleName string, module config.Module, configured bool) (*probeClient, error) {
+	key := fmt.Sprintf("%s|%s|%s", moduleName, target, site)
+
+	h.mu.Lock()
+	now := time.Now()
+	h.evict(now)
+	probe, ok := h.clients[key]
+	if !ok {
+		probe = &probeClient{}
+		h.clients[key] = probe
+	}
+	probe.lastUsed = now
+	h.mu.Unlock()
+
+	probe.once.Do(func() {
+		conf := h.probeConfig(target, site, module, configured)
+		probe.client, probe.err = api.Configure(&conf)
+		if probe.err == nil {
+			probe.collectors = enabledCollectors(probe.client, h.conf, nil)
+		}
+	})
+	if probe.err != nil {
+		// the failed client isn't kept, so the next probe logs in again
+		h.mu.Lock()
+		if h.clients[key] == probe {
+			delete(h.clients, key)
+		}
+		h.mu.Unlock()
+		return nil, probe.err
+	}
+
+	return probe, nil
+}
+
+// probeConfig returns the config to log in to the target with the module's credentials
+func (h *probeHandler) probeConfig(target string, site string, module config.Module, configured bool) config.Config {
+	timeout := module.Timeout
+	if timeout == 0 {
+		timeout = h.conf.Timeout
+	}
+
+	authMode := module.AuthMode
+	if authMode == "" {
+		authMode = config.AuthModeWeb
+	}
+
+	// the collector options, like the client labels, apply to probes too
+	conf := *h.conf
+	conf.Host = target
+	conf.AuthMode = authMode
+	conf.Username = module.Username
+	conf.Password = module.Password
+	conf.ClientId = module.ClientId
+	conf.ClientSecret = module.ClientSecret
+	conf.Site = site
+	conf.AllSites = false
+	conf.IncludeSites = nil
+	conf.ExcludeSites = nil
+	conf.Timeout = timeout
+	// --insecure only applies to the configured controllers, other targets need the module to skip verification
+	conf.Insecure = module.Insecure || (configured && h.conf.Insecure)
+
+	return conf
+}
+
+// evict removes the clients unused for probeClientTTL, and the least recently used client when there's no room for another
+func (h *probeHandler) evict(now time.Time) {
+	var oldest string
+	for key, probe := range h.clients {
+		if now.Sub(probe.lastUsed) > probeClientTTL {
+			delete(h.clients, key)
+			continue
+		}
+		if oldest == "" || probe.lastUsed.Before(h.clients[oldest].lastUsed) {
+			oldest = key
+		}
+	}
+	if len(h.clients) >= probeMaxClients {
+		delete(h.clients, oldest)
+	}
+}
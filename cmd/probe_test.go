@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charlie-haley/omada_exporter/pkg/config"
+)
+
+func TestProbeModuleTargets(t *testing.T) {
+	conf := &config.Config{
+		Host:        "https://omada.local",
+		Username:    "user",
+		Password:    "password",
+		Site:        "Default",
+		Timeout:     1,
+		Controllers: map[string]config.Controller{"branch": {Host: "http://127.0.0.1:1"}},
+		Modules: map[string]config.Module{
+			"other":  {Username: "other", Password: "password", Timeout: 1},
+			"listed": {Username: "listed", Password: "password", Timeout: 1, Targets: []string{"http://127.0.0.1:2/"}},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"arbitrary target", "target=https://attacker.example", http.StatusBadRequest},
+		{"arbitrary target with default module", "target=https://attacker.example&module=default", http.StatusBadRequest},
+		{"arbitrary target with module", "target=https://attacker.example&module=other", http.StatusBadRequest},
+		{"configured controller", "target=branch", http.StatusOK},
+		{"configured controller with module", "target=branch&module=other", http.StatusOK},
+		{"configured host with module", "target=http://127.0.0.1:1/&module=other", http.StatusOK},
+		{"module target", "target=http://127.0.0.1:2&module=listed", http.StatusOK},
+		{"module target with another module", "target=http://127.0.0.1:2&module=other", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newProbeHandler(conf)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", "/probe?"+tt.query, nil))
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestProbeConfigInsecure(t *testing.T) {
+	tests := []struct {
+		name       string
+		insecure   bool
+		module     bool
+		configured bool
+		expected   bool
+	}{
+		{"verified", false, false, true, false},
+		{"flag for configured controller", true, false, true, true},
+		{"flag for module target", true, false, false, false},
+		{"module for module target", false, true, false, true},
+		{"module for configured controller", false, true, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newProbeHandler(&config.Config{Insecure: tt.insecure})
+			conf := h.probeConfig("https://omada.local", "Default", config.Module{Insecure: tt.module}, tt.configured)
+			if conf.Insecure != tt.expected {
+				t.Errorf("expected insecure %t, got %t", tt.expected, conf.Insecure)
+			}
+		})
+	}
+}
+
+func TestProbeLogsInOnce(t *testing.T) {
+	var mu sync.Mutex
+	logins := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch strings.TrimPrefix(r.URL.Path, "/cid") {
+		case "/api/info":
+			mu.Lock()
+			logins += 1
+			mu.Unlock()
+			// a slow controller, so concurrent probes arrive while logging in
+			time.Sleep(100 * time.Millisecond)
+			w.Write([]byte(`{"errorCode":0,"result":{"omadacId":"cid"}}`))
+		case "/api/v2/loginStatus":
+			w.Write([]byte(`{"errorCode":0,"result":{"login":true}}`))
+		case "/api/v2/login":
+			w.Write([]byte(`{"errorCode":0,"result":{"token":"token"}}`))
+		case "/api/v2/users/current":
+			w.Write([]byte(`{"errorCode":0,"result":{"privilege":{"sites":[{"name":"Default","key":"s1"}]}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	h := newProbeHandler(&config.Config{Host: server.URL, Username: "user", Password: "password", Site: "Default", Timeout: 5})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", "/probe?target="+server.URL, nil))
+		}()
+	}
+	wg.Wait()
+
+	if logins != 1 {
+		t.Errorf("expected the target to be logged in to once, got %d", logins)
+	}
+	if len(h.clients) != 1 {
+		t.Errorf("expected 1 cached client, got %d", len(h.clients))
+	}
+}
+
+func TestProbeUnreachableTarget(t *testing.T) {
+	h := newProbeHandler(&config.Config{Host: "http://127.0.0.1:1", Username: "user", Password: "password", Timeout: 1})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/probe?target=http://127.0.0.1:1", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "omada_up 0") {
+		t.Errorf("expected omada_up 0, got %s", w.Body.String())
+	}
+	if len(h.clients) != 0 {
+		t.Errorf("expected the failed client not to be cached")
+	}
+}
+
+func TestProbeEvict(t *testing.T) {
+	h := newProbeHandler(&config.Config{})
+	now := time.Now()
+	for i := 0; i < probeMaxClients; i++ {
+		h.clients[fmt.Sprint(i)] = &probeClient{lastUsed: now.Add(time.Duration(i) * time.Second)}
+	}
+	h.clients["stale"] = &probeClient{lastUsed: now.Add(-2 * probeClientTTL)}
+
+	h.evict(now.Add(time.Minute))
+
+	if _, ok := h.clients["stale"]; ok {
+		t.Error("expected the stale client to be evicted")
+	}
+	if _, ok := h.clients["0"]; ok {
+		t.Error("expected the least recently used client to be evicted")
+	}
+	if len(h.clients) != probeMaxClients-1 {
+		t.Errorf("expected %d clients, got %d", probeMaxClients-1, len(h.clients))
+	}
+}
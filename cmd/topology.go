@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/charlie-haley/omada_exporter/pkg/api"
+	zerolog "github.com/rs/zerolog"
+	"github.com/urfave/cli/v2"
+)
+
+// topology prints the devices of each site as a tree of the devices they're connected through
+func topology(c *cli.Context) error {
+	format := c.String("format")
+	if format != "text" && format != "dot" {
+		return fmt.Errorf("invalid format %q, must be \"text\" or \"dot\"", format)
+	}
+
+	conf, err := loadConfig(c)
+	if err != nil {
+		return err
+	}
+	level, err := zerolog.ParseLevel(conf.LogLevel)
+	if err != nil {
+		return err
+	}
+	zerolog.SetGlobalLevel(level)
+
+	client, err := api.Configure(conf)
+	if err != nil {
+		return err
+	}
+
+	trees := []*siteTree{}
+	for _, site := range client.Sites {
+		devices, err := client.GetDeviceList(site.Id)
+		if err != nil {
+			return fmt.Errorf("failed to get devices for site %s: %s", site.Name, err)
+		}
+		trees = append(trees, newSiteTree(site, devices))
+	}
+
+	if format == "dot" {
+		printTopologyDot(os.Stdout, trees)
+	} else {
+		printTopologyText(os.Stdout, trees)
+	}
+	return nil
+}
+
+// siteTree is the devices of a site keyed by the mac of the device they're connected through. Devices whose
+// uplinks form a loop can't be reached from any root and are kept apart as unrooted.
+type siteTree struct {
+	site     api.Site
+	devices  []api.Device
+	roots    []api.Device
+	unrooted []api.Device
+	children map[string][]api.Device
+}
+
+func newSiteTree(site api.Site, devices []api.Device) *siteTree {
+	t := &siteTree{site: site, devices: devices, children: map[string][]api.Device{}}
+
+	known := map[string]bool{}
+	for _, d := range devices {
+		known[d.Mac] = true
+	}
+	// devices without an uplink, or connected through something other than an omada device, are roots of the tree
+	for _, d := range devices {
+		if d.UplinkDeviceMac == "" || !known[d.UplinkDeviceMac] {
+			t.roots = append(t.roots, d)
+		} else {
+			t.children[d.UplinkDeviceMac] = append(t.children[d.UplinkDeviceMac], d)
+		}
+	}
+
+	reached := map[string]bool{}
+	var reach func(devices []api.Device)
+	reach = func(devices []api.Device) {
+		for _, d := range devices {
+			if !reached[d.Mac] {
+				reached[d.Mac] = true
+				reach(t.children[d.Mac])
+			}
+		}
+	}
+	reach(t.roots)
+	for _, d := range devices {
+		if !reached[d.Mac] {
+			t.unrooted = append(t.unrooted, d)
+		}
+	}
+
+	byName := func(s []api.Device) {
+		sort.SliceStable(s, func(i, j int) bool { return s[i].Name < s[j].Name })
+	}
+	byName(t.roots)
+	byName(t.unrooted)
+	for _, c := range t.children {
+		byName(c)
+	}
+	return t
+}
+
+// uplinkName returns the name of the device d is connected through
+func (t *siteTree) uplinkName(d api.Device) string {
+	for _, u := range t.devices {
+		if u.Mac == d.UplinkDeviceMac {
+			return u.Name
+		}
+	}
+	return d.UplinkDeviceMac
+}
+
+func uplinkDescription(d api.Device) string {
+	if d.UplinkDeviceMac == "" {
+		return ""
+	}
+	if d.WirelessLinked {
+		return "mesh"
+	}
+	return fmt.Sprintf("port %.0f", d.UplinkDevicePort)
+}
+
+func printTopologyText(w io.Writer, trees []*siteTree) {
+	for _, t := range trees {
+		fmt.Fprintln(w, t.site.Name)
+		visited := map[string]bool{}
+		var walk func(devices []api.Device, prefix string, more bool)
+		walk = func(devices []api.Device, prefix string, more bool) {
+			for i, d := range devices {
+				// a loop in the reported uplinks would otherwise recurse forever
+				if visited[d.Mac] {
+					continue
+				}
+				visited[d.Mac] = true
+
+				branch, indent := "├── ", "│   "
+				if i == len(devices)-1 && !more {
+					branch, indent = "└── ", "    "
+				}
+				line := fmt.Sprintf("%s%s%s (%s, %s)", prefix, branch, d.Name, d.Mac, d.Type)
+				if uplink := uplinkDescription(d); uplink != "" {
+					line += " via " + uplink
+				}
+				fmt.Fprintln(w, line)
+				walk(t.children[d.Mac], prefix+indent, false)
+			}
+		}
+		walk(t.roots, "", len(t.unrooted) > 0)
+
+		// the devices in an uplink loop are listed flat with the device each one reports as its uplink
+		if len(t.unrooted) > 0 {
+			fmt.Fprintln(w, "└── unrooted")
+			for i, d := range t.unrooted {
+				branch := "├── "
+				if i == len(t.unrooted)-1 {
+					branch = "└── "
+				}
+				fmt.Fprintf(w, "    %s%s (%s, %s) via %s of %s\n", branch, d.Name, d.Mac, d.Type, uplinkDescription(d), t.uplinkName(d))
+			}
+		}
+	}
+}
+
+func printTopologyDot(w io.Writer, trees []*siteTree) {
+	fmt.Fprintln(w, "digraph omada {")
+	for _, t := range trees {
+		fmt.Fprintf(w, "\tsubgraph %q {\n", "cluster_"+t.site.Id)
+		fmt.Fprintf(w, "\t\tlabel=%q;\n", t.site.Name)
+		unrooted := map[string]bool{}
+		for _, d := range t.unrooted {
+			unrooted[d.Mac] = true
+		}
+		for _, d := range t.devices {
+			if !unrooted[d.Mac] {
+				fmt.Fprintf(w, "\t\t%q [label=%q];\n", d.Mac, strings.Join([]string{d.Name, d.Mac, d.Type}, "\n"))
+			}
+		}
+		if len(t.unrooted) > 0 {
+			fmt.Fprintf(w, "\t\tsubgraph %q {\n", "cluster_"+t.site.Id+"_unrooted")
+			fmt.Fprintln(w, "\t\t\tlabel=\"unrooted\";")
+			for _, d := range t.unrooted {
+				fmt.Fprintf(w, "\t\t\t%q [label=%q];\n", d.Mac, strings.Join([]string{d.Name, d.Mac, d.Type}, "\n"))
+			}
+			fmt.Fprintln(w, "\t\t}")
+		}
+		fmt.Fprintln(w, "\t}")
+	}
+	for _, t := range trees {
+		for _, d := range t.devices {
+			if _, ok := t.children[d.UplinkDeviceMac]; !ok {
+				continue
+			}
+			style := ""
+			if d.WirelessLinked {
+				style = ", style=dashed"
+			}
+			fmt.Fprintf(w, "\t%q -> %q [label=%q%s];\n", d.UplinkDeviceMac, d.Mac, uplinkDescription(d), style)
+		}
+	}
+	fmt.Fprintln(w, "}")
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/charlie-haley/omada_exporter/pkg/api"
+)
+
+func TestTopologyText(t *testing.T) {
+	tests := []struct {
+		name    string
+		devices []api.Device
+		output  string
+	}{
+		{
+			"tree",
+			[]api.Device{
+				{Name: "gw", Mac: "01", Type: "gateway"},
+				{Name: "sw", Mac: "02", Type: "switch", UplinkDeviceMac: "01", UplinkDevicePort: 1},
+				{Name: "ap1", Mac: "03", Type: "ap", UplinkDeviceMac: "02", UplinkDevicePort: 5},
+				{Name: "ap2", Mac: "04", Type: "ap", UplinkDeviceMac: "03", WirelessLinked: true},
+			},
+			`Default
+└── gw (01, gateway)
+    └── sw (02, switch) via port 1
+        └── ap1 (03, ap) via port 5
+            └── ap2 (04, ap) via mesh
+`,
+		},
+		{
+			"uplink loop",
+			[]api.Device{
+				{Name: "gw", Mac: "01", Type: "gateway"},
+				{Name: "ap1", Mac: "03", Type: "ap", UplinkDeviceMac: "04", WirelessLinked: true},
+				{Name: "ap2", Mac: "04", Type: "ap", UplinkDeviceMac: "03", WirelessLinked: true},
+				{Name: "ap3", Mac: "05", Type: "ap", UplinkDeviceMac: "04", WirelessLinked: true},
+			},
+			`Default
+├── gw (01, gateway)
+└── unrooted
+    ├── ap1 (03, ap) via mesh of ap2
+    ├── ap2 (04, ap) via mesh of ap1
+    └── ap3 (05, ap) via mesh of ap2
+`,
+		},
+		{
+			"own uplink",
+			[]api.Device{
+				{Name: "sw", Mac: "02", Type: "switch", UplinkDeviceMac: "02", UplinkDevicePort: 3},
+			},
+			`Default
+└── unrooted
+    └── sw (02, switch) via port 3 of sw
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			printTopologyText(&out, []*siteTree{newSiteTree(api.Site{Name: "Default", Id: "s1"}, tt.devices)})
+			if out.String() != tt.output {
+				t.Errorf("expected\n%s\ngot\n%s", tt.output, out.String())
+			}
+		})
+	}
+}
+
+func TestTopologyDotUnrooted(t *testing.T) {
+	devices := []api.Device{
+		{Name: "gw", Mac: "01", Type: "gateway"},
+		{Name: "ap1", Mac: "03", Type: "ap", UplinkDeviceMac: "04", WirelessLinked: true},
+		{Name: "ap2", Mac: "04", Type: "ap", UplinkDeviceMac: "03", WirelessLinked: true},
+	}
+	var out bytes.Buffer
+	printTopologyDot(&out, []*siteTree{newSiteTree(api.Site{Name: "Default", Id: "s1"}, devices)})
+
+	for _, expected := range []string{
+		`subgraph "cluster_s1_unrooted" {`,
+		`"03" [label="ap1\n03\nap"];`,
+		`"04" -> "03" [label="mesh", style=dashed];`,
+		`"03" -> "04" [label="mesh", style=dashed];`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %s in\n%s", expected, out.String())
+		}
+	}
+	if strings.Index(out.String(), `"01" [label`) > strings.Index(out.String(), "cluster_s1_unrooted") {
+		t.Errorf("expected only the unrooted devices in the unrooted cluster\n%s", out.String())
+	}
+}
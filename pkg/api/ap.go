@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	log "github.com/rs/zerolog/log"
+)
+
+func (c *Client) GetAccessPoint(siteId string, mac string) (*AccessPoint, error) {
+	url := c.siteURL(siteId, fmt.Sprintf("eaps/%s", mac))
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.get(req, "eap")
+	if err != nil {
+		return nil, err
+	}
+	log.Debug().Bytes("data", body).Msg("Received data from eap endpoint")
+
+	apdata := accessPointResponse{}
+	err = json.Unmarshal(body, &apdata)
+
+	return &apdata.Result, err
+}
+
+type accessPointResponse struct {
+	Result AccessPoint `json:"result"`
+}
+type AccessPoint struct {
+	Name            string         `json:"name"`
+	Mac             string         `json:"mac"`
+	Wp2g            *RadioSettings `json:"wp2g"`
+	Wp5g            *RadioSettings `json:"wp5g"`
+	Wp5g2           *RadioSettings `json:"wp5g2"`
+	Wp6g            *RadioSettings `json:"wp6g"`
+	RadioTraffic2g  RadioTraffic   `json:"radioTraffic2g"`
+	RadioTraffic5g  RadioTraffic   `json:"radioTraffic5g"`
+	RadioTraffic5g2 RadioTraffic   `json:"radioTraffic5g2"`
+	RadioTraffic6g  RadioTraffic   `json:"radioTraffic6g"`
+	ClientNum2g     float64        `json:"clientNum2g"`
+	ClientNum5g     float64        `json:"clientNum5g"`
+	ClientNum5g2    float64        `json:"clientNum5g2"`
+	ClientNum6g     float64        `json:"clientNum6g"`
+}
+type RadioSettings struct {
+	ActualChannel string  `json:"actualChannel"`
+	BandWidth     string  `json:"bandWidth"`
+	TxPower       float64 `json:"txPower"`
+	TxUtil        float64 `json:"txUtil"`
+	RxUtil        float64 `json:"rxUtil"`
+	InterUtil     float64 `json:"interUtil"`
+	BusyUtil      float64 `json:"busyUtil"`
+}
+type RadioTraffic struct {
+	Rx          float64 `json:"rx"`
+	Tx          float64 `json:"tx"`
+	RxPkts      float64 `json:"rxPkts"`
+	TxPkts      float64 `json:"txPkts"`
+	RxDropPkts  float64 `json:"rxDropPkts"`
+	TxDropPkts  float64 `json:"txDropPkts"`
+	RxRetryPkts float64 `json:"rxRetryPkts"`
+	TxRetryPkts float64 `json:"txRetryPkts"`
+}
+
+// bands are the radio bands as labelled in metrics, indexed by the radio id the omada API reports
+var bands = []string{"2.4GHz", "5GHz", "5GHz-2", "6GHz"}
+
+// Band returns the band of a radio id
+func Band(radioId int) string {
+	if radioId < 0 || radioId >= len(bands) {
+		return ""
+	}
+	return bands[radioId]
+}
+
+// Radio is a single band of an access point
+type Radio struct {
+	Band     string
+	Settings RadioSettings
+	Traffic  RadioTraffic
+	Clients  float64
+}
+
+// Radios returns the radios the access point has, the omada API returns each band as separate fields
+func (ap *AccessPoint) Radios() []Radio {
+	radios := []Radio{}
+	if ap.Wp2g != nil {
+		radios = append(radios, Radio{bands[0], *ap.Wp2g, ap.RadioTraffic2g, ap.ClientNum2g})
+	}
+	if ap.Wp5g != nil {
+		radios = append(radios, Radio{bands[1], *ap.Wp5g, ap.RadioTraffic5g, ap.ClientNum5g})
+	}
+	if ap.Wp5g2 != nil {
+		radios = append(radios, Radio{bands[2], *ap.Wp5g2, ap.RadioTraffic5g2, ap.ClientNum5g2})
+	}
+	if ap.Wp6g != nil {
+		radios = append(radios, Radio{bands[3], *ap.Wp6g, ap.RadioTraffic6g, ap.ClientNum6g})
+	}
+	return radios
+}
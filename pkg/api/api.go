@@ -2,9 +2,13 @@ package api
 
 import (
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/charlie-haley/omada_exporter/pkg/config"
@@ -14,9 +18,18 @@ import (
 type Client struct {
 	Config     *config.Config
 	httpClient *http.Client
+	// token is the CSRF token of the web UI session, only used by the webAuthenticator under its lock
 	token      string
 	omadaCID   string
-	SiteId     string
+	auth       authenticator
+	Sites      []Site
+	Metrics    *Metrics
+	snapshotMu sync.Mutex
+	snapshot   *snapshot
+	// clockOffset is how far the controller's clock is ahead of the exporter's, see Now
+	clockMu     sync.Mutex
+	clockOffset time.Duration
+	clockKnown  bool
 }
 
 func setuphttpClient(insecure bool, timeout int) (*http.Client, error) {
@@ -47,6 +60,12 @@ func Configure(c *config.Config) (*Client, error) {
 	client := &Client{
 		Config:     c,
 		httpClient: httpClient,
+		Metrics:    NewMetrics(),
+	}
+	if client.openAPI() {
+		client.auth = &openAPIAuthenticator{client: client}
+	} else {
+		client.auth = &webAuthenticator{client: client}
 	}
 	cid, err := client.getCid()
 	if err != nil {
@@ -54,41 +73,147 @@ func Configure(c *config.Config) (*Client, error) {
 	}
 	client.omadaCID = cid
 
-	sid, err := client.getSiteId(c.Site)
+	sites, err := client.getConfiguredSites()
 	if err != nil {
 		return nil, err
 	}
-	client.SiteId = *sid
+	client.Sites = sites
 
 	return client, nil
 }
 
-func (c *Client) makeRequest(req *http.Request) (*http.Response, error) {
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("X-Requested-With", "XMLHttpRequest")
-	req.Header.Add("User-Agent", "omada_exporter")
-	req.Header.Add("Connection", "keep-alive")
-
-	if c.token != "" {
-		req.Header.Add("Csrf-Token", c.token)
+// makeRequest sends a request to the controller, recording its duration and status code against the endpoint
+func (c *Client) makeRequest(req *http.Request, endpoint string) (*http.Response, error) {
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	req.Header.Set("User-Agent", "omada_exporter")
+	req.Header.Set("Connection", "keep-alive")
+
+	begin := time.Now()
+	res, err := c.httpClient.Do(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(res.StatusCode)
+		c.observeClock(res, begin)
 	}
+	c.Metrics.omadaApiRequestsTotal.WithLabelValues(endpoint, code).Inc()
+	c.Metrics.omadaApiRequestDuration.WithLabelValues(endpoint, code).Observe(time.Since(begin).Seconds())
 
-	return c.httpClient.Do(req)
+	return res, err
 }
 
-func (c *Client) makeLoggedInRequest(req *http.Request) (*http.Response, error) {
-	loggedIn, err := c.IsLoggedIn()
+func (c *Client) makeLoggedInRequest(req *http.Request, endpoint string) (*http.Response, error) {
+	err := c.auth.authorize(req)
 	if err != nil {
 		return nil, err
 	}
-	if !loggedIn {
-		log.Info().Msg(fmt.Sprintf("not logged in, logging in with user: %s", c.Config.Username))
-		err := c.Login()
-		if err != nil || c.token == "" {
-			log.Error().Err(err).Msg("failed to login")
+
+	return c.makeRequest(req, endpoint)
+}
+
+func (c *Client) openAPI() bool {
+	return c.Config.AuthMode == config.AuthModeOpenAPI
+}
+
+// siteURL returns the URL of an endpoint under a site, the OpenAPI and the web UI endpoints live under different paths
+func (c *Client) siteURL(siteId string, path string) string {
+	if c.openAPI() {
+		return fmt.Sprintf("%s/openapi/v1/%s/sites/%s/%s", c.Config.Host, c.omadaCID, siteId, path)
+	}
+	return fmt.Sprintf("%s/%s/api/v2/sites/%s/%s", c.Config.Host, c.omadaCID, siteId, path)
+}
+
+// addPage adds the query parameters to fetch a page of a paged endpoint, the
+// OpenAPI names them differently to the web UI endpoints
+func (c *Client) addPage(q url.Values, page int, pageSize int) {
+	if c.openAPI() {
+		// the OpenAPI rejects page sizes over 1000
+		if pageSize > 1000 {
+			pageSize = 1000
+		}
+		q.Set("page", strconv.Itoa(page))
+		q.Set("pageSize", strconv.Itoa(pageSize))
+		return
+	}
+	q.Set("currentPage", strconv.Itoa(page))
+	q.Set("currentPageSize", strconv.Itoa(pageSize))
+}
+
+// getPages fetches every page of a paged endpoint with the query parameters in q, until as many items as the
+// controller reports in totalRows have been returned. A page size of 0 fetches the endpoint once without paging.
+func getPages[T any](c *Client, url string, q url.Values, pageSize int, endpoint string) ([]T, error) {
+	items := []T{}
+	for page := 1; ; page++ {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		query := req.URL.Query()
+		for k, v := range q {
+			query[k] = v
+		}
+		if pageSize > 0 {
+			c.addPage(query, page, pageSize)
+		}
+		req.URL.RawQuery = query.Encode()
+
+		body, err := c.get(req, endpoint)
+		if err != nil {
+			return nil, err
+		}
+		log.Debug().Bytes("data", body).Msg(fmt.Sprintf("Received data from %s endpoint", endpoint))
+
+		res := pagedResponse[T]{}
+		err = json.Unmarshal(body, &res)
+		if err != nil {
 			return nil, err
 		}
+		items = append(items, res.Result.Data...)
+		if pageSize <= 0 || len(res.Result.Data) == 0 || len(items) >= res.Result.TotalRows {
+			return items, nil
+		}
+	}
+}
+
+type pagedResponse[T any] struct {
+	Result pagedResult[T] `json:"result"`
+}
+
+// pagedResult decodes a page of a paged result, or a plain array as a single page holding every item
+type pagedResult[T any] struct {
+	TotalRows int
+	Data      []T
+}
+
+func (p *pagedResult[T]) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '[' {
+		err := json.Unmarshal(b, &p.Data)
+		p.TotalRows = len(p.Data)
+		return err
 	}
+	paged := struct {
+		TotalRows int `json:"totalRows"`
+		Data      []T `json:"data"`
+	}{}
+	err := json.Unmarshal(b, &paged)
+	p.TotalRows, p.Data = paged.TotalRows, paged.Data
+	return err
+}
 
-	return c.makeRequest(req)
+// listResult decodes a list returned either as a plain array or as the "data" of a paged result
+type listResult[T any] []T
+
+func (l *listResult[T]) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '{' {
+		paged := struct {
+			Data []T `json:"data"`
+		}{}
+		err := json.Unmarshal(b, &paged)
+		*l = paged.Data
+		return err
+	}
+	list := []T{}
+	err := json.Unmarshal(b, &list)
+	*l = list
+	return err
 }
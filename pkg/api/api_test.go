@@ -0,0 +1,102 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/charlie-haley/omada_exporter/pkg/config"
+	"github.com/rs/zerolog"
+)
+
+func TestMain(m *testing.M) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	os.Exit(m.Run())
+}
+
+// noAuth authorises every request without logging in
+type noAuth struct{}
+
+func (noAuth) authorize(req *http.Request) error             { return nil }
+func (noAuth) expired(req *http.Request, errorCode int) bool { return false }
+
+// newTestClient returns a client for the server which doesn't log in
+func newTestClient(server *httptest.Server, authMode string) *Client {
+	return &Client{
+		Config:     &config.Config{Host: server.URL, AuthMode: authMode},
+		httpClient: server.Client(),
+		omadaCID:   "cid",
+		auth:       noAuth{},
+		Metrics:    NewMetrics(),
+	}
+}
+
+// pagedServer serves total items from a paged endpoint, counting the requests made
+func pagedServer(total int, requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests += 1
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+
+		items := []string{}
+		for i := (page - 1) * size; i < page*size && i < total; i++ {
+			items = append(items, fmt.Sprintf(`{"mac":"%d"}`, i))
+		}
+		fmt.Fprintf(w, `{"errorCode":0,"result":{"totalRows":%d,"data":[%s]}}`, total, strings.Join(items, ","))
+	}))
+}
+
+func TestGetPages(t *testing.T) {
+	tests := []struct {
+		name     string
+		total    int
+		requests int
+	}{
+		{"empty", 0, 1},
+		{"single page", 10, 1},
+		{"full page", 1000, 1},
+		{"several pages", 2500, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := pagedServer(tt.total, &requests)
+			defer server.Close()
+			c := newTestClient(server, config.AuthModeOpenAPI)
+
+			clients, err := c.GetClients("s1")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(clients) != tt.total {
+				t.Errorf("expected %d clients, got %d", tt.total, len(clients))
+			}
+			if requests != tt.requests {
+				t.Errorf("expected %d requests, got %d", tt.requests, requests)
+			}
+		})
+	}
+}
+
+func TestGetPagesArray(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		w.Write([]byte(`{"errorCode":0,"result":[{"mac":"a","type":"ap"},{"mac":"b","type":"ap"}]}`))
+	}))
+	defer server.Close()
+	c := newTestClient(server, config.AuthModeWeb)
+
+	devices, err := c.GetDevices("s1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(devices) != 2 || requests != 1 {
+		t.Errorf("expected 2 devices from 1 request, got %d from %d", len(devices), requests)
+	}
+}
@@ -6,8 +6,65 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
+
+	log "github.com/rs/zerolog/log"
 )
 
+// authenticator authorises requests to the controller, logging in first if needed
+type authenticator interface {
+	authorize(req *http.Request) error
+	// expired returns whether the controller rejected the credentials of the request with errorCode,
+	// forgetting them so that the request can be retried with new ones
+	expired(req *http.Request, errorCode int) bool
+}
+
+// webAuthenticator logs in to the web UI endpoints with a username and password, the
+// session is kept in the cookie jar and the CSRF token is added to every request
+type webAuthenticator struct {
+	client *Client
+	// mu guards the client's token, and makes concurrent requests wait for a single login
+	mu sync.Mutex
+}
+
+func (a *webAuthenticator) authorize(req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	c := a.client
+	loggedIn, err := c.IsLoggedIn()
+	if err != nil {
+		return err
+	}
+	if !loggedIn {
+		log.Info().Msg(fmt.Sprintf("not logged in, logging in with user: %s", c.Config.Username))
+		c.Metrics.omadaApiLoginAttemptsTotal.Inc()
+		err := c.Login()
+		if err != nil || c.token == "" {
+			c.Metrics.omadaApiLoginFailuresTotal.Inc()
+			log.Error().Err(err).Msg("failed to login")
+			if err == nil {
+				err = fmt.Errorf("no token returned from login")
+			}
+			return err
+		}
+	}
+	c.addCsrfToken(req)
+	return nil
+}
+
+// addCsrfToken adds the CSRF token returned by the last login to a request
+func (c *Client) addCsrfToken(req *http.Request) {
+	if c.token != "" {
+		req.Header.Set("Csrf-Token", c.token)
+	}
+}
+
+// the session is checked before every request, so a rejected request is only retried when the session ended in between
+func (a *webAuthenticator) expired(req *http.Request, errorCode int) bool {
+	return errorCode == webNotLoggedIn
+}
+
 func (c *Client) IsLoggedIn() (bool, error) {
 	loginstatus := loginStatus{}
 
@@ -16,8 +73,9 @@ func (c *Client) IsLoggedIn() (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	c.addCsrfToken(req)
 
-	res, err := c.makeRequest(req)
+	res, err := c.makeRequest(req, "loginStatus")
 	if err != nil {
 		return false, err
 	}
@@ -29,7 +87,7 @@ func (c *Client) IsLoggedIn() (bool, error) {
 	}
 
 	err = json.Unmarshal(body, &loginstatus)
-	if loginstatus.ErrorCode == -1200 {
+	if loginstatus.ErrorCode == webNotLoggedIn {
 		return false, nil
 	}
 	if loginstatus.ErrorCode != 0 {
@@ -47,7 +105,7 @@ func (c *Client) getCid() (string, error) {
 		return "", err
 	}
 
-	res, err := c.makeRequest(req)
+	res, err := c.makeRequest(req, "info")
 	if err != nil {
 		return "", err
 	}
@@ -84,7 +142,7 @@ func (c *Client) Login() error {
 	}
 
 	req.Header.Add("Content-Type", "application/json; charset=UTF-8")
-	res, err := c.makeRequest(req)
+	res, err := c.makeRequest(req, "login")
 	if err != nil {
 		return err
 	}
@@ -104,6 +162,9 @@ func (c *Client) Login() error {
 	return nil
 }
 
+// the error code the web UI endpoints respond with when the session isn't logged in
+const webNotLoggedIn = -1200
+
 type loginResponse struct {
 	Result loginResult `json:"result"`
 }
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/charlie-haley/omada_exporter/pkg/config"
+)
+
+func TestWebLoginConcurrentRequests(t *testing.T) {
+	var mu sync.Mutex
+	logins := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/cid/api/v2/loginStatus":
+			if logins == 0 {
+				w.Write([]byte(`{"errorCode":-1200}`))
+				return
+			}
+			w.Write([]byte(`{"errorCode":0,"result":{"login":true}}`))
+		case "/cid/api/v2/login":
+			logins += 1
+			w.Write([]byte(`{"errorCode":0,"result":{"token":"token"}}`))
+		default:
+			if r.Header.Get("Csrf-Token") != "token" {
+				t.Errorf("expected the CSRF token on %s", r.URL.Path)
+			}
+			w.Write([]byte(`{"errorCode":0,"result":{"data":[]}}`))
+		}
+	}))
+	defer server.Close()
+
+	c := newTestClient(server, config.AuthModeWeb)
+	c.auth = &webAuthenticator{client: c}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.GetClients("s1")
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if logins != 1 {
+		t.Errorf("expected a single login for concurrent requests, got %d", logins)
+	}
+}
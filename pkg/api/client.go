@@ -2,79 +2,24 @@ package api
 
 import (
 	"encoding/json"
-	"fmt"
-	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	log "github.com/rs/zerolog/log"
 )
 
-// gets clients by switch mac address
-func (c *Client) GetClientByPort(switchMac string, port float64) (*NetworkClient, error) {
-	clients, err := c.getClientsWithFilters(true, switchMac)
-	if err != nil {
-		return nil, err
-	}
-	for _, client := range clients {
-		if client.Port == port {
-			return &client, nil
-		}
-	}
-	return nil, nil
-}
-
-// gets all clients
-func (c *Client) GetClients() ([]NetworkClient, error) {
-	client, err := c.getClientsWithFilters(false, "")
-	if err != nil {
-		return nil, err
-	}
+// the number of clients fetched per page, the OpenAPI fetches at most 1000
+const clientPageSize = 10000
 
-	return client, nil
-}
-
-// gets clients by filters in omada - currentl supports SwitchMac
-func (c *Client) getClientsWithFilters(filtersEnabled bool, mac string) ([]NetworkClient, error) {
-	url := fmt.Sprintf("%s/%s/api/v2/sites/%s/clients", c.Config.Host, c.omadaCID, c.SiteId)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	q := req.URL.Query()
-	q.Add("currentPage", "1")
-	q.Add("currentPageSize", "10000")
+// gets all active clients
+func (c *Client) GetClients(siteId string) ([]NetworkClient, error) {
+	q := url.Values{}
 	q.Add("filters.active", "true")
-	if filtersEnabled {
-		q.Add("filters.switchMac=", mac)
-	}
-
-	req.URL.RawQuery = q.Encode()
-
-	resp, err := c.makeLoggedInRequest(req)
-	if err != nil {
-		return nil, err
-	}
-
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	log.Debug().Bytes("data", body).Msg("Received data from clients endpoint")
-
-	clientdata := clientResponse{}
-	err = json.Unmarshal(body, &clientdata)
-
-	return clientdata.Result.Data, err
+	return getPages[NetworkClient](c, c.siteURL(siteId, "clients"), q, clientPageSize, "clients")
 }
 
-type clientResponse struct {
-	Result data `json:"result"`
-}
-type data struct {
-	Data []NetworkClient `json:"data"`
-}
 type NetworkClient struct {
 	Name        string  `json:"name"`
 	HostName    string  `json:"hostName"`
@@ -85,6 +30,7 @@ type NetworkClient struct {
 	ApName      string  `json:"apName"`
 	Wireless    bool    `json:"wireless"`
 	SwitchMac   string  `json:"switchMac"`
+	SwitchName  string  `json:"switchName"`
 	Vendor      string  `json:"vendor"`
 	Activity    float64 `json:"activity"`
 	SignalLevel float64 `json:"signalLevel"`
@@ -94,6 +40,87 @@ type NetworkClient struct {
 	Rssi        float64 `json:"rssi"`
 	TrafficDown float64 `json:"trafficDown"`
 	TrafficUp   float64 `json:"trafficUp"`
+	RadioId     float64 `json:"radioId"`
 	RxRate      float64 `json:"rxRate"`
 	TxRate      float64 `json:"txRate"`
+	Uptime      float64 `json:"uptime"`
+	FirstSeen   float64 `json:"firstSeen"`
+	LastSeen    float64 `json:"lastSeen"`
+}
+
+// ConnectedSince returns when the client connected as a unix timestamp, from the controller's
+// last seen time when it's reported so that the exporter's clock doesn't matter
+func (n *NetworkClient) ConnectedSince(now time.Time) float64 {
+	if n.LastSeen > 0 {
+		return n.LastSeen/1000 - n.Uptime
+	}
+	return float64(now.Unix()) - n.Uptime
+}
+
+// the number of past connections fetched per page, and the most pages fetched in one call
+const (
+	pastClientPageSize = 1000
+	pastClientMaxPages = 10
+)
+
+// GetPastClients returns the client connections to a site which ended between start and end, and the number
+// which weren't fetched because there were more than pastClientMaxPages pages of them
+func (c *Client) GetPastClients(siteId string, start time.Time, end time.Time) ([]PastClient, int, error) {
+	path := "insight/pastConnection"
+	if c.openAPI() {
+		path = "insight/past-connection"
+	}
+
+	pastClients := []PastClient{}
+	totalRows := 0
+	for page := 1; page <= pastClientMaxPages; page++ {
+		req, err := http.NewRequest("GET", c.siteURL(siteId, path), nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		q := req.URL.Query()
+		c.addPage(q, page, pastClientPageSize)
+		q.Add("filters.timeStart", strconv.FormatInt(start.UnixMilli(), 10))
+		q.Add("filters.timeEnd", strconv.FormatInt(end.UnixMilli(), 10))
+		req.URL.RawQuery = q.Encode()
+
+		body, err := c.get(req, "pastConnection")
+		if err != nil {
+			return nil, 0, err
+		}
+		log.Debug().Bytes("data", body).Msg("Received data from pastConnection endpoint")
+
+		pastdata := pastClientResponse{}
+		err = json.Unmarshal(body, &pastdata)
+		if err != nil {
+			return nil, 0, err
+		}
+		pastClients = append(pastClients, pastdata.Result.Data...)
+		totalRows = pastdata.Result.TotalRows
+		if len(pastdata.Result.Data) < pastClientPageSize || len(pastClients) >= totalRows {
+			break
+		}
+	}
+	skipped := 0
+	if len(pastClients) < totalRows {
+		skipped = totalRows - len(pastClients)
+		log.Warn().Str("site", siteId).Int("fetched", len(pastClients)).Int("total", totalRows).
+			Msg("Too many past client connections to fetch, the counts of the day will be short")
+	}
+
+	return pastClients, skipped, nil
+}
+
+type pastClientResponse struct {
+	Result struct {
+		TotalRows int          `json:"totalRows"`
+		Data      []PastClient `json:"data"`
+	} `json:"result"`
+}
+type PastClient struct {
+	Mac       string  `json:"mac"`
+	Name      string  `json:"name"`
+	FirstSeen float64 `json:"firstSeen"`
+	LastSeen  float64 `json:"lastSeen"`
+	Duration  float64 `json:"duration"`
 }
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/charlie-haley/omada_exporter/pkg/config"
+)
+
+func TestGetPastClients(t *testing.T) {
+	tests := []struct {
+		name     string
+		total    int
+		fetched  int
+		skipped  int
+		requests int
+	}{
+		{"empty", 0, 0, 0, 1},
+		{"several pages", 2500, 2500, 0, 3},
+		{"every page", 10000, 10000, 0, 10},
+		{"too many pages", 12345, 10000, 2345, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := pagedServer(tt.total, &requests)
+			defer server.Close()
+			c := newTestClient(server, config.AuthModeOpenAPI)
+
+			end := time.Now()
+			pastClients, skipped, err := c.GetPastClients("s1", end.Add(-time.Hour), end)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(pastClients) != tt.fetched {
+				t.Errorf("expected %d past clients, got %d", tt.fetched, len(pastClients))
+			}
+			if skipped != tt.skipped {
+				t.Errorf("expected %d skipped, got %d", tt.skipped, skipped)
+			}
+			if requests != tt.requests {
+				t.Errorf("expected %d requests, got %d", tt.requests, requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+// observeClock records how far the controller's clock is from the exporter's, from the Date header of a response
+func (c *Client) observeClock(res *http.Response, sent time.Time) {
+	date, err := http.ParseTime(res.Header.Get("Date"))
+	if err != nil {
+		return
+	}
+	// the header only has second precision, so it's taken as the middle of that second, compared
+	// with the middle of the request
+	local := sent.Add(time.Since(sent) / 2)
+
+	c.clockMu.Lock()
+	defer c.clockMu.Unlock()
+	c.clockOffset = date.Add(time.Second / 2).Sub(local)
+	c.clockKnown = true
+}
+
+// Now returns the current time by the controller's clock, or the exporter's until the controller has responded
+func (c *Client) Now() time.Time {
+	c.clockMu.Lock()
+	defer c.clockMu.Unlock()
+	now := time.Now()
+	if !c.clockKnown {
+		return now
+	}
+	return now.Add(c.clockOffset)
+}
@@ -3,7 +3,6 @@ package api
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 
 	log "github.com/rs/zerolog/log"
@@ -11,18 +10,15 @@ import (
 
 func (c *Client) GetController() (*Controller, error) {
 	url := fmt.Sprintf("%s/%s/api/v2/maintenance/controllerStatus?", c.Config.Host, c.omadaCID)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
+	if c.openAPI() {
+		url = fmt.Sprintf("%s/openapi/v1/%s/maintenance/controller-status", c.Config.Host, c.omadaCID)
 	}
-
-	resp, err := c.makeLoggedInRequest(req)
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	body, err := c.get(req, "controllerStatus")
 	if err != nil {
 		return nil, err
 	}
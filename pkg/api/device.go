@@ -3,56 +3,128 @@ package api
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	log "github.com/rs/zerolog/log"
 )
 
-func (c *Client) GetDevices() ([]Device, error) {
-	url := fmt.Sprintf("%s/%s/api/v2/sites/%s/devices", c.Config.Host, c.omadaCID, c.SiteId)
-	req, err := http.NewRequest("GET", url, nil)
+// GetDevices returns the devices of a site, with the ports of each switch
+func (c *Client) GetDevices(siteId string) ([]Device, error) {
+	devices, err := c.GetDeviceList(siteId)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := c.makeLoggedInRequest(req)
+	for i, d := range devices {
+		if d.Type == "switch" {
+			switchPorts, err := c.GetPorts(siteId, d.Mac)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get ports: %s", err)
+			}
+			devices[i].Ports = switchPorts
+		}
+	}
+
+	return devices, nil
+}
+
+// GetDeviceList returns the devices of a site without the ports of the switches, which take a request per switch
+func (c *Client) GetDeviceList(siteId string) ([]Device, error) {
+	// the web UI returns every device at once, only the OpenAPI pages them
+	pageSize := 0
+	if c.openAPI() {
+		pageSize = 1000
+	}
+	return getPages[Device](c, c.siteURL(siteId, "devices"), url.Values{}, pageSize, "devices")
+}
+
+// GetDeviceDetail returns the device from its detail endpoint, which reports hardware health the device list doesn't.
+// Only switches and gateways have a detail endpoint, other devices are returned as they are.
+func (c *Client) GetDeviceDetail(siteId string, device Device) (*Device, error) {
+	var path string
+	switch device.Type {
+	case "switch":
+		path = fmt.Sprintf("switches/%s", device.Mac)
+	case "gateway":
+		path = fmt.Sprintf("gateways/%s", device.Mac)
+	default:
+		return &device, nil
+	}
+
+	url := c.siteURL(siteId, path)
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	body, err := c.get(req, device.Type)
 	if err != nil {
 		return nil, err
 	}
-	log.Debug().Bytes("data", body).Msg("Received data from devices endpoint")
+	log.Debug().Bytes("data", body).Msgf("Received data from %s endpoint", device.Type)
 
-	devicedata := deviceResponse{}
-	err = json.Unmarshal(body, &devicedata)
+	detaildata := deviceDetailResponse{Result: device}
+	err = json.Unmarshal(body, &detaildata)
 
-	for i, d := range devicedata.Result {
-		if d.Type == "switch" {
-			switchPorts, err := c.GetPorts(d.Mac)
-			if err != nil {
-				return nil, fmt.Errorf("failed to get ports: %s", err)
-			}
-			devicedata.Result[i].Ports = switchPorts
-		}
+	return &detaildata.Result, err
+}
+
+// GetLatestFirmware returns the latest firmware available for a device
+func (c *Client) GetLatestFirmware(siteId string, mac string) (*Firmware, error) {
+	url := c.siteURL(siteId, fmt.Sprintf("devices/%s/latest-firmware-info", mac))
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	return devicedata.Result, err
+	body, err := c.get(req, "latest-firmware-info")
+	if err != nil {
+		return nil, err
+	}
+	log.Debug().Bytes("data", body).Msg("Received data from latest-firmware-info endpoint")
+
+	firmwaredata := firmwareResponse{}
+	err = json.Unmarshal(body, &firmwaredata)
+
+	return &firmwaredata.Result, err
 }
 
-type deviceResponse struct {
-	Result []Device `json:"result"`
+type firmwareResponse struct {
+	Result Firmware `json:"result"`
 }
+type Firmware struct {
+	Version string `json:"lastFwVer"`
+	// the release date is either a timestamp in milliseconds or a date string depending on the controller version
+	ReleaseDate json.RawMessage `json:"releaseDate"`
+}
+
+// Released returns the release date of the firmware as YYYY-MM-DD, or an empty string if it isn't known
+func (f *Firmware) Released() string {
+	var ms float64
+	if err := json.Unmarshal(f.ReleaseDate, &ms); err == nil && ms > 0 {
+		return time.UnixMilli(int64(ms)).UTC().Format("2006-01-02")
+	}
+	var date string
+	if err := json.Unmarshal(f.ReleaseDate, &date); err == nil {
+		return date
+	}
+	return ""
+}
+
+type deviceDetailResponse struct {
+	Result Device `json:"result"`
+}
+
 type Device struct {
 	Name        string  `json:"name"`
 	Type        string  `json:"type"`
 	Mac         string  `json:"mac"`
 	Model       string  `json:"model"`
 	Version     string  `json:"version"`
+	HwVersion   string  `json:"hwVersion"`
 	Ip          string  `json:"ip"`
 	CpuUtil     float64 `json:"cpuUtil"`
 	MemUtil     float64 `json:"memUtil"`
@@ -61,7 +133,127 @@ type Device struct {
 	TxRate      float64 `json:"txRate"`
 	RxRate      float64 `json:"rxRate"`
 	PoeRemain   float64 `json:"poeRemain"`
+	PoeBudget   float64 `json:"totalPower"`
 	Ports       []Port  `json:"ports"`
 	Download    int64   `json:"download"`
+	StatusCode  float64 `json:"status"`
+	LastSeen    float64 `json:"lastSeen"`
 	Upload      int64   `json:"upload"`
+	// devices connected to another omada device report the device and port they're connected through,
+	// access points connected over mesh have no uplink port
+	UplinkDeviceMac  string  `json:"uplinkDeviceMac"`
+	UplinkDeviceName string  `json:"uplinkDeviceName"`
+	UplinkDevicePort float64 `json:"uplinkDevicePort"`
+	WirelessLinked   bool    `json:"wirelessLinked"`
+	// the link quality of an access point's mesh uplink, only set when WirelessLinked
+	WirelessUplink *WirelessUplink `json:"wirelessUplink"`
+	// hardware health is only reported by the detail endpoints, and only by models with the sensors
+	Temperatures  []TemperatureSensor `json:"temperatures"`
+	Fans          []Fan               `json:"fans"`
+	PowerSupplies []PowerSupply       `json:"powerSupplies"`
+}
+type TemperatureSensor struct {
+	Name        string  `json:"name"`
+	Temperature float64 `json:"temperature"`
+}
+type Fan struct {
+	Name   string  `json:"name"`
+	Status string  `json:"status"`
+	Speed  float64 `json:"speed"`
+}
+type PowerSupply struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// the status of a device, the omada API reports a detailed status code which is grouped into these
+const (
+	DeviceStatusConnected       = "connected"
+	DeviceStatusDisconnected    = "disconnected"
+	DeviceStatusPending         = "pending"
+	DeviceStatusIsolated        = "isolated"
+	DeviceStatusUpgrading       = "upgrading"
+	DeviceStatusProvisioning    = "provisioning"
+	DeviceStatusHeartbeatMissed = "heartbeat_missed"
+)
+
+var DeviceStatuses = []string{
+	DeviceStatusConnected,
+	DeviceStatusDisconnected,
+	DeviceStatusPending,
+	DeviceStatusIsolated,
+	DeviceStatusUpgrading,
+	DeviceStatusProvisioning,
+	DeviceStatusHeartbeatMissed,
+}
+
+// Status returns the status of the device, or an empty string for a status code that isn't known
+func (d *Device) Status() string {
+	code := int(d.StatusCode)
+	switch {
+	case code == 0 || code == 1:
+		return DeviceStatusDisconnected
+	case code == 12:
+		return DeviceStatusUpgrading
+	case code >= 10 && code <= 13:
+		// provisioning, configuring and rebooting
+		return DeviceStatusProvisioning
+	case code >= 14 && code <= 17:
+		return DeviceStatusConnected
+	case code >= 20 && code <= 27:
+		// pending, adopting, adoption failed and managed by others
+		return DeviceStatusPending
+	case code >= 30 && code <= 33:
+		// including wireless and migrating devices
+		return DeviceStatusHeartbeatMissed
+	case code >= 40 && code <= 43:
+		// including wireless and migrating devices
+		return DeviceStatusIsolated
+	}
+	return ""
+}
+
+// HealthyStatus returns whether a fan or power supply status is normal
+func HealthyStatus(status string) bool {
+	switch strings.ToLower(status) {
+	case "normal", "ok", "on":
+		return true
+	}
+	return false
+}
+
+type WirelessUplink struct {
+	UplinkMac string  `json:"uplinkMac"`
+	Name      string  `json:"name"`
+	Channel   float64 `json:"channel"`
+	Rssi      float64 `json:"rssi"`
+	Snr       float64 `json:"snr"`
+	TxRate    float64 `json:"txRate"`
+	RxRate    float64 `json:"rxRate"`
+}
+
+// MeshHops returns how many wireless links there are between a device and the first wired device
+// above it, 0 for a device that isn't connected over mesh. An uplink that isn't in the list counts
+// as the last hop. It returns false if the reported uplinks loop back to a device already passed,
+// so there's no wired device above it to count to.
+func MeshHops(device Device, devices []Device) (int, bool) {
+	byMac := map[string]Device{}
+	for _, d := range devices {
+		byMac[d.Mac] = d
+	}
+
+	passed := map[string]bool{}
+	hops := 0
+	for d := device; d.WirelessLinked; hops++ {
+		passed[d.Mac] = true
+		uplink, ok := byMac[d.UplinkDeviceMac]
+		if !ok {
+			return hops + 1, true
+		}
+		if passed[uplink.Mac] {
+			return 0, false
+		}
+		d = uplink
+	}
+	return hops, true
 }
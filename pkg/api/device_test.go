@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestMeshHops(t *testing.T) {
+	devices := []Device{
+		{Mac: "root"},
+		{Mac: "hop1", WirelessLinked: true, UplinkDeviceMac: "root"},
+		{Mac: "hop2", WirelessLinked: true, UplinkDeviceMac: "hop1"},
+		{Mac: "hop3", WirelessLinked: true, UplinkDeviceMac: "hop2"},
+		{Mac: "orphan", WirelessLinked: true, UplinkDeviceMac: "unknown"},
+		{Mac: "loop1", WirelessLinked: true, UplinkDeviceMac: "loop2"},
+		{Mac: "loop2", WirelessLinked: true, UplinkDeviceMac: "loop1"},
+		{Mac: "self", WirelessLinked: true, UplinkDeviceMac: "self"},
+		{Mac: "below-loop", WirelessLinked: true, UplinkDeviceMac: "loop1"},
+	}
+
+	tests := []struct {
+		mac  string
+		hops int
+		ok   bool
+	}{
+		{"root", 0, true},
+		{"hop1", 1, true},
+		{"hop2", 2, true},
+		{"hop3", 3, true},
+		{"orphan", 1, true},
+		{"loop1", 0, false},
+		{"loop2", 0, false},
+		{"self", 0, false},
+		{"below-loop", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mac, func(t *testing.T) {
+			for _, d := range devices {
+				if d.Mac != tt.mac {
+					continue
+				}
+				hops, ok := MeshHops(d, devices)
+				if hops != tt.hops || ok != tt.ok {
+					t.Errorf("expected %d hops and %v, got %d and %v", tt.hops, tt.ok, hops, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestDeviceStatus(t *testing.T) {
+	tests := []struct {
+		code   float64
+		status string
+	}{
+		{0, DeviceStatusDisconnected},
+		{1, DeviceStatusDisconnected},
+		{10, DeviceStatusProvisioning},
+		{11, DeviceStatusProvisioning},
+		{12, DeviceStatusUpgrading},
+		{13, DeviceStatusProvisioning},
+		{14, DeviceStatusConnected},
+		{17, DeviceStatusConnected},
+		{20, DeviceStatusPending},
+		{27, DeviceStatusPending},
+		{30, DeviceStatusHeartbeatMissed},
+		{32, DeviceStatusHeartbeatMissed},
+		{33, DeviceStatusHeartbeatMissed},
+		{40, DeviceStatusIsolated},
+		{42, DeviceStatusIsolated},
+		{43, DeviceStatusIsolated},
+		{2, ""},
+		{18, ""},
+		{34, ""},
+		{44, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%.0f", tt.code), func(t *testing.T) {
+			d := Device{StatusCode: tt.code}
+			if status := d.Status(); status != tt.status {
+				t.Errorf("expected %q for %.0f, got %q", tt.status, tt.code, status)
+			}
+		})
+	}
+}
+
+func TestFirmwareReleased(t *testing.T) {
+	tests := []struct {
+		name        string
+		releaseDate string
+		released    string
+	}{
+		{"milliseconds", `1700000000000`, "2023-11-14"},
+		{"date", `"2023-11-14"`, "2023-11-14"},
+		{"zero", `0`, ""},
+		{"null", `null`, ""},
+		{"missing", ``, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Firmware{ReleaseDate: json.RawMessage(tt.releaseDate)}
+			if released := f.Released(); released != tt.released {
+				t.Errorf("expected %q, got %q", tt.released, released)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	log "github.com/rs/zerolog/log"
+)
+
+func (c *Client) GetGateway(siteId string, mac string) (*Gateway, error) {
+	url := c.siteURL(siteId, fmt.Sprintf("gateways/%s", mac))
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.get(req, "gateway")
+	if err != nil {
+		return nil, err
+	}
+	log.Debug().Bytes("data", body).Msg("Received data from gateway endpoint")
+
+	gatewaydata := gatewayResponse{}
+	err = json.Unmarshal(body, &gatewaydata)
+
+	return &gatewaydata.Result, err
+}
+
+type gatewayResponse struct {
+	Result Gateway `json:"result"`
+}
+type Gateway struct {
+	Name      string        `json:"name"`
+	Mac       string        `json:"mac"`
+	Model     string        `json:"model"`
+	PortStats []GatewayPort `json:"portStats"`
+}
+
+// the mode of a gateway port, WAN ports are the ones connected to an ISP
+const GatewayPortModeWan = 0
+
+type GatewayPort struct {
+	Port          float64     `json:"port"`
+	Name          string      `json:"name"`
+	Mode          float64     `json:"mode"`
+	Ip            string      `json:"ip"`
+	Status        float64     `json:"status"`
+	InternetState float64     `json:"internetState"`
+	Speed         float64     `json:"speed"`
+	Rx            float64     `json:"rx"`
+	Tx            float64     `json:"tx"`
+	RxRate        float64     `json:"rxRate"`
+	TxRate        float64     `json:"txRate"`
+	Latency       float64     `json:"latency"`
+	Loss          json.Number `json:"loss"`
+	IspName       string      `json:"ispName"`
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	log "github.com/rs/zerolog/log"
+)
+
+// GetLldpNeighbors returns the neighbors a switch has discovered over LLDP
+func (c *Client) GetLldpNeighbors(siteId string, switchMac string) ([]LldpNeighbor, error) {
+	url := c.siteURL(siteId, fmt.Sprintf("switches/%s/lldp", switchMac))
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.get(req, "lldp")
+	if err != nil {
+		return nil, err
+	}
+	log.Debug().Bytes("data", body).Msg("Received data from lldp endpoint")
+
+	lldpdata := lldpResponse{}
+	err = json.Unmarshal(body, &lldpdata)
+
+	return lldpdata.Result, err
+}
+
+type lldpResponse struct {
+	Result listResult[LldpNeighbor] `json:"result"`
+}
+type LldpNeighbor struct {
+	Port              float64 `json:"port"`
+	ChassisId         string  `json:"chassisId"`
+	PortId            string  `json:"portId"`
+	PortDescription   string  `json:"portDescription"`
+	SystemName        string  `json:"systemName"`
+	ManagementAddress string  `json:"managementAddress"`
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	log "github.com/rs/zerolog/log"
+)
+
+// the kinds of site logs kept by the controller
+const (
+	LogAlerts = "alerts"
+	LogEvents = "events"
+)
+
+// the number of entries fetched per page of logs
+const logPageSize = 1000
+
+// GetLogs returns every alert or event log of a site from start until now, by the controller's clock.
+// The end is fixed before paging, so entries logged in the meantime don't shift the pages.
+func (c *Client) GetLogs(siteId string, kind string, start time.Time) ([]LogEntry, error) {
+	return getPages[LogEntry](c, c.siteURL(siteId, c.logPath(kind)), url.Values{
+		"filters.timeStart": {strconv.FormatInt(start.UnixMilli(), 10)},
+		"filters.timeEnd":   {strconv.FormatInt(c.Now().UnixMilli(), 10)},
+	}, logPageSize, kind)
+}
+
+// GetUnarchivedAlerts returns the number of alerts of a site that haven't been archived
+func (c *Client) GetUnarchivedAlerts(siteId string) (float64, error) {
+	logdata, err := c.getLogPage(siteId, LogAlerts, 1, 1, url.Values{
+		"filters.resolved": {"false"},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return float64(logdata.TotalRows), nil
+}
+
+// logPath returns the path of the logs of a kind under a site
+func (c *Client) logPath(kind string) string {
+	if c.openAPI() {
+		return "logs/" + kind
+	}
+	return kind
+}
+
+func (c *Client) getLogPage(siteId string, kind string, page int, pageSize int, filters url.Values) (*logPage, error) {
+	req, err := http.NewRequest("GET", c.siteURL(siteId, c.logPath(kind)), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	q := req.URL.Query()
+	c.addPage(q, page, pageSize)
+	for k, v := range filters {
+		q[k] = v
+	}
+	req.URL.RawQuery = q.Encode()
+
+	body, err := c.get(req, kind)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug().Bytes("data", body).Msgf("Received data from %s endpoint", kind)
+
+	logdata := logResponse{}
+	err = json.Unmarshal(body, &logdata)
+
+	return &logdata.Result, err
+}
+
+type logResponse struct {
+	Result logPage `json:"result"`
+}
+type logPage struct {
+	TotalRows int        `json:"totalRows"`
+	Data      []LogEntry `json:"data"`
+}
+type LogEntry struct {
+	Id       string `json:"id"`
+	Time     int64  `json:"time"`
+	Key      string `json:"key"`
+	Level    string `json:"level"`
+	Module   string `json:"module"`
+	Content  string `json:"content"`
+	Resolved bool   `json:"resolved"`
+}
+
+// LogCursor is the time of the newest log entry seen and the ids of the entries seen at that
+// time, so that logs can be tailed without returning entries logged in the same millisecond twice
+type LogCursor struct {
+	Time int64           `json:"time"`
+	Ids  map[string]bool `json:"ids"`
+}
+
+// NewLogCursor returns a cursor that skips every entry logged before t
+func NewLogCursor(t time.Time) LogCursor {
+	return LogCursor{Time: t.UnixMilli(), Ids: map[string]bool{}}
+}
+
+// Start returns the time to fetch logs from
+func (c LogCursor) Start() time.Time {
+	return time.UnixMilli(c.Time)
+}
+
+// Next returns the entries not seen by the cursor, oldest first and each id once, and the cursor advanced past them
+func (c LogCursor) Next(entries []LogEntry) ([]LogEntry, LogCursor) {
+	next := LogCursor{Time: c.Time, Ids: map[string]bool{}}
+	unseen := []LogEntry{}
+	returned := map[string]bool{}
+	for _, entry := range entries {
+		if entry.Time < c.Time || (entry.Time == c.Time && c.Ids[entry.Id]) || returned[entry.Id] {
+			continue
+		}
+		returned[entry.Id] = true
+		unseen = append(unseen, entry)
+		if entry.Time > next.Time {
+			next.Time = entry.Time
+		}
+	}
+	sort.SliceStable(unseen, func(i, j int) bool { return unseen[i].Time < unseen[j].Time })
+
+	if next.Time == c.Time {
+		for id := range c.Ids {
+			next.Ids[id] = true
+		}
+	}
+	for _, entry := range unseen {
+		if entry.Time == next.Time {
+			next.Ids[entry.Id] = true
+		}
+	}
+
+	return unseen, next
+}
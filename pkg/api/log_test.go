@@ -0,0 +1,143 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charlie-haley/omada_exporter/pkg/config"
+)
+
+func TestLogCursorNext(t *testing.T) {
+	tests := []struct {
+		name    string
+		cursor  LogCursor
+		entries []LogEntry
+		unseen  []string
+		next    LogCursor
+	}{
+		{
+			"no entries",
+			LogCursor{Time: 100, Ids: map[string]bool{"a": true}},
+			nil,
+			nil,
+			LogCursor{Time: 100, Ids: map[string]bool{"a": true}},
+		},
+		{
+			"newest first",
+			LogCursor{Time: 100, Ids: map[string]bool{}},
+			[]LogEntry{{Id: "c", Time: 300}, {Id: "b", Time: 200}, {Id: "a", Time: 100}},
+			[]string{"a", "b", "c"},
+			LogCursor{Time: 300, Ids: map[string]bool{"c": true}},
+		},
+		{
+			"older entries skipped",
+			LogCursor{Time: 200, Ids: map[string]bool{}},
+			[]LogEntry{{Id: "b", Time: 250}, {Id: "a", Time: 150}},
+			[]string{"b"},
+			LogCursor{Time: 250, Ids: map[string]bool{"b": true}},
+		},
+		{
+			"seen entries at the cursor skipped",
+			LogCursor{Time: 200, Ids: map[string]bool{"a": true}},
+			[]LogEntry{{Id: "b", Time: 200}, {Id: "a", Time: 200}},
+			[]string{"b"},
+			LogCursor{Time: 200, Ids: map[string]bool{"a": true, "b": true}},
+		},
+		{
+			"several entries at the newest time",
+			LogCursor{Time: 100, Ids: map[string]bool{"a": true}},
+			[]LogEntry{{Id: "c", Time: 300}, {Id: "b", Time: 300}, {Id: "a", Time: 100}},
+			[]string{"c", "b"},
+			LogCursor{Time: 300, Ids: map[string]bool{"b": true, "c": true}},
+		},
+		{
+			"duplicates across pages returned once",
+			LogCursor{Time: 100, Ids: map[string]bool{}},
+			[]LogEntry{{Id: "b", Time: 200}, {Id: "a", Time: 150}, {Id: "a", Time: 150}},
+			[]string{"a", "b"},
+			LogCursor{Time: 200, Ids: map[string]bool{"b": true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unseen, next := tt.cursor.Next(tt.entries)
+			ids := []string{}
+			for _, entry := range unseen {
+				ids = append(ids, entry.Id)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.unseen, ",") {
+				t.Errorf("expected entries %q, got %q", tt.unseen, ids)
+			}
+			if next.Time != tt.next.Time || fmt.Sprint(next.Ids) != fmt.Sprint(tt.next.Ids) {
+				t.Errorf("expected cursor %v, got %v", tt.next, next)
+			}
+		})
+	}
+}
+
+func TestGetLogsFetchesEveryPage(t *testing.T) {
+	total := 12500
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		page, _ := strconv.Atoi(r.URL.Query().Get("currentPage"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("currentPageSize"))
+
+		entries := []string{}
+		// newest first, like the controller
+		for i := (page - 1) * size; i < page*size && i < total; i++ {
+			entries = append(entries, fmt.Sprintf(`{"id":"%d","time":%d}`, i, total-i))
+		}
+		fmt.Fprintf(w, `{"errorCode":0,"result":{"totalRows":%d,"data":[%s]}}`, total, strings.Join(entries, ","))
+	}))
+	defer server.Close()
+	c := newTestClient(server, config.AuthModeWeb)
+
+	entries, err := c.GetLogs("s1", LogEvents, time.UnixMilli(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	unseen, next := NewLogCursor(time.UnixMilli(0)).Next(entries)
+	if len(unseen) != total || requests != 13 {
+		t.Errorf("expected %d entries from 13 requests, got %d from %d", total, len(unseen), requests)
+	}
+	if unseen[0].Time != 1 || next.Time != int64(total) {
+		t.Errorf("expected entries from 1 to %d, got %d to %d", total, unseen[0].Time, next.Time)
+	}
+}
+
+func TestClientNowUsesControllerClock(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset time.Duration
+	}{
+		{"in sync", 0},
+		{"controller ahead", 10 * time.Minute},
+		{"controller behind", -3 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Date", time.Now().Add(tt.offset).UTC().Format(http.TimeFormat))
+				w.Write([]byte(`{"errorCode":0,"result":{}}`))
+			}))
+			defer server.Close()
+			c := newTestClient(server, config.AuthModeWeb)
+
+			_, err := c.GetController()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := c.Now().Sub(time.Now().Add(tt.offset)); diff < -time.Second || diff > time.Second {
+				t.Errorf("expected the controller's time, off by %s", diff)
+			}
+		})
+	}
+}
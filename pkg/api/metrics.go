@@ -0,0 +1,49 @@
+package api
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics records the requests made to the controller, so that a slow or failing
+// controller API is visible even when collectors only partially fail
+type Metrics struct {
+	omadaApiRequestsTotal      *prometheus.CounterVec
+	omadaApiRequestDuration    *prometheus.HistogramVec
+	omadaApiLoginAttemptsTotal prometheus.Counter
+	omadaApiLoginFailuresTotal prometheus.Counter
+}
+
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.omadaApiRequestsTotal.Describe(ch)
+	m.omadaApiRequestDuration.Describe(ch)
+	m.omadaApiLoginAttemptsTotal.Describe(ch)
+	m.omadaApiLoginFailuresTotal.Describe(ch)
+}
+
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.omadaApiRequestsTotal.Collect(ch)
+	m.omadaApiRequestDuration.Collect(ch)
+	m.omadaApiLoginAttemptsTotal.Collect(ch)
+	m.omadaApiLoginFailuresTotal.Collect(ch)
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		omadaApiRequestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "omada_api_requests_total",
+			Help: "Total number of requests made to the controller API.",
+		}, []string{"endpoint", "code"}),
+		omadaApiRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "omada_api_request_duration_seconds",
+			Help: "Duration of requests made to the controller API.",
+		}, []string{"endpoint", "code"}),
+		omadaApiLoginAttemptsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "omada_api_login_attempts_total",
+			Help: "Total number of attempts to log in to the controller.",
+		}),
+		omadaApiLoginFailuresTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "omada_api_login_failures_total",
+			Help: "Total number of failed attempts to log in to the controller.",
+		}),
+	}
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	log "github.com/rs/zerolog/log"
+)
+
+// openAPIAuthenticator authorises requests to the official Omada OpenAPI using
+// an access token obtained with the client credentials grant
+type openAPIAuthenticator struct {
+	client       *Client
+	mu           sync.Mutex
+	accessToken  string
+	refreshToken string
+	expiry       time.Time
+}
+
+func (a *openAPIAuthenticator) authorize(req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// renew the token slightly before it expires so in-flight requests don't fail
+	if a.accessToken == "" || time.Now().Add(time.Minute).After(a.expiry) {
+		a.client.Metrics.omadaApiLoginAttemptsTotal.Inc()
+		err := a.renew()
+		if err != nil {
+			a.client.Metrics.omadaApiLoginFailuresTotal.Inc()
+			log.Error().Err(err).Msg("failed to get OpenAPI access token")
+			return err
+		}
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("AccessToken=%s", a.accessToken))
+	return nil
+}
+
+// renew refreshes the access token if there's a refresh token, falling back to the client credentials grant
+func (a *openAPIAuthenticator) renew() error {
+	if a.refreshToken != "" {
+		err := a.requestToken(url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {a.client.Config.ClientId},
+			"client_secret": {a.client.Config.ClientSecret},
+			"refresh_token": {a.refreshToken},
+		}, nil)
+		if err == nil {
+			return nil
+		}
+		log.Warn().Err(err).Msg("failed to refresh OpenAPI access token, requesting a new one")
+	}
+
+	log.Info().Msg(fmt.Sprintf("requesting OpenAPI access token with client: %s", a.client.Config.ClientId))
+	body, err := json.Marshal(tokenRequest{
+		OmadaCID:     a.client.omadaCID,
+		ClientId:     a.client.Config.ClientId,
+		ClientSecret: a.client.Config.ClientSecret,
+	})
+	if err != nil {
+		return err
+	}
+	return a.requestToken(url.Values{"grant_type": {"client_credentials"}}, body)
+}
+
+// expired forgets the access token when the controller rejected it before it expired locally, e.g. after the
+// controller restarted, so that a new one is requested. Only the token the request was sent with is forgotten,
+// in case another request has already renewed it.
+func (a *openAPIAuthenticator) expired(req *http.Request, errorCode int) bool {
+	if errorCode != openAPITokenExpired && errorCode != openAPITokenInvalid {
+		return false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if req.Header.Get("Authorization") == fmt.Sprintf("AccessToken=%s", a.accessToken) {
+		a.accessToken = ""
+	}
+	return true
+}
+
+func (a *openAPIAuthenticator) requestToken(params url.Values, body []byte) error {
+	url := fmt.Sprintf("%s/openapi/authorize/token?%s", a.client.Config.Host, params.Encode())
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("Content-Type", "application/json; charset=UTF-8")
+	res, err := a.client.makeRequest(req, "token")
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	tokendata := tokenResponse{}
+	err = json.Unmarshal(data, &tokendata)
+	if err != nil {
+		return err
+	}
+	if tokendata.ErrorCode != 0 || tokendata.Result.AccessToken == "" {
+		return fmt.Errorf("invalid token response from API: %s", tokendata.Msg)
+	}
+
+	a.accessToken = tokendata.Result.AccessToken
+	a.refreshToken = tokendata.Result.RefreshToken
+	a.expiry = time.Now().Add(time.Duration(tokendata.Result.ExpiresIn) * time.Second)
+	return nil
+}
+
+// the error codes the OpenAPI responds with when the access token has expired or isn't valid
+const (
+	openAPITokenExpired = -44112
+	openAPITokenInvalid = -44113
+)
+
+type tokenRequest struct {
+	OmadaCID     string `json:"omadacId"`
+	ClientId     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+type tokenResponse struct {
+	ErrorCode int         `json:"errorCode"`
+	Msg       string      `json:"msg"`
+	Result    tokenResult `json:"result"`
+}
+type tokenResult struct {
+	AccessToken  string `json:"accessToken"`
+	TokenType    string `json:"tokenType"`
+	ExpiresIn    int    `json:"expiresIn"`
+	RefreshToken string `json:"refreshToken"`
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/charlie-haley/omada_exporter/pkg/config"
+)
+
+func TestOpenAPITokenRequestEscapesCredentials(t *testing.T) {
+	var got tokenRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := json.NewDecoder(r.Body).Decode(&got)
+		if err != nil {
+			t.Errorf("token request isn't valid JSON: %s", err)
+		}
+		w.Write([]byte(`{"errorCode":0,"result":{"accessToken":"token","expiresIn":7200}}`))
+	}))
+	defer server.Close()
+
+	c := newTestClient(server, config.AuthModeOpenAPI)
+	c.Config.ClientId = `id"\`
+	c.Config.ClientSecret = `se"cr\et`
+	a := &openAPIAuthenticator{client: c}
+
+	err := a.renew()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got.ClientId != c.Config.ClientId || got.ClientSecret != c.Config.ClientSecret || got.OmadaCID != "cid" {
+		t.Errorf("unexpected token request %+v", got)
+	}
+}
+
+func TestOpenAPIRetriesRejectedToken(t *testing.T) {
+	tokens := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/openapi/authorize/token" {
+			tokens += 1
+			fmt.Fprintf(w, `{"errorCode":0,"result":{"accessToken":"token-%d","expiresIn":7200}}`, tokens)
+			return
+		}
+		// the controller only accepts the second token, as if it restarted after the first was issued
+		if r.Header.Get("Authorization") != "AccessToken=token-2" {
+			fmt.Fprintf(w, `{"errorCode":%d,"msg":"expired"}`, openAPITokenExpired)
+			return
+		}
+		w.Write([]byte(`{"errorCode":0,"result":{"totalRows":1,"data":[{"mac":"a"}]}}`))
+	}))
+	defer server.Close()
+
+	c := newTestClient(server, config.AuthModeOpenAPI)
+	c.auth = &openAPIAuthenticator{client: c}
+
+	clients, err := c.GetClients("s1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(clients) != 1 {
+		t.Errorf("expected 1 client after retrying, got %d", len(clients))
+	}
+	if tokens != 2 {
+		t.Errorf("expected 2 token requests, got %d", tokens)
+	}
+}
@@ -3,26 +3,19 @@ package api
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 
 	log "github.com/rs/zerolog/log"
 )
 
-func (c *Client) GetPorts(switchMac string) ([]Port, error) {
-	url := fmt.Sprintf("%s/%s/api/v2/sites/%s/switches/%s/ports", c.Config.Host, c.omadaCID, c.SiteId, switchMac)
+func (c *Client) GetPorts(siteId string, switchMac string) ([]Port, error) {
+	url := c.siteURL(siteId, fmt.Sprintf("switches/%s/ports", switchMac))
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := c.makeLoggedInRequest(req)
-	if err != nil {
-		return nil, err
-	}
-
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	body, err := c.get(req, "ports")
 	if err != nil {
 		return nil, err
 	}
@@ -35,7 +28,7 @@ func (c *Client) GetPorts(switchMac string) ([]Port, error) {
 }
 
 type portResponse struct {
-	Result []Port `json:"result"`
+	Result listResult[Port] `json:"result"`
 }
 type Port struct {
 	Id          string     `json:"id"`
@@ -45,13 +38,78 @@ type Port struct {
 	PortStatus  portStatus `json:"portStatus"`
 	Port        float64    `json:"port"`
 	ProfileName string     `json:"profileName"`
+	// 1 when PoE is enabled on the port
+	PoeMode     float64 `json:"poe"`
+	PoePriority float64 `json:"poePriority"`
 }
+
+// the PoE status of a port
+const (
+	PoeStatusDelivering = "delivering"
+	PoeStatusSearching  = "searching"
+	PoeStatusOverload   = "overload"
+	PoeStatusDisabled   = "disabled"
+)
+
+var PoeStatuses = []string{PoeStatusDelivering, PoeStatusSearching, PoeStatusOverload, PoeStatusDisabled}
+
+// PoeStatus returns whether the port is delivering power, waiting for a powered device, overloaded or has PoE disabled
+func (p *Port) PoeStatus() string {
+	switch {
+	case p.PoeMode != 1:
+		return PoeStatusDisabled
+	case p.PortStatus.PoeOverload:
+		return PoeStatusOverload
+	case p.PortStatus.PoePower > 0:
+		return PoeStatusDelivering
+	}
+	return PoeStatusSearching
+}
+
+// the spanning tree state of a port
+const (
+	StpStateForwarding = "forwarding"
+	StpStateDiscarding = "discarding"
+	StpStateDisabled   = "disabled"
+)
+
+var StpStates = []string{StpStateForwarding, StpStateDiscarding, StpStateDisabled}
+
+// StpState returns whether spanning tree is forwarding on the port, discarding its traffic to break a loop, or
+// whether the port is down. The controller only reports whether a port is discarding, so a port that's learning
+// is reported as forwarding.
+func (p *Port) StpState() string {
+	switch {
+	case p.PortStatus.LinkStatus != 1:
+		return StpStateDisabled
+	case p.PortStatus.StpDiscarding:
+		return StpStateDiscarding
+	}
+	return StpStateForwarding
+}
+
 type portStatus struct {
-	Port       float64 `json:"id"`
-	LinkStatus float64 `json:"linkStatus"`
-	LinkSpeed  float64 `json:"linkSpeed"`
-	PoePower   float64 `json:"poePower"`
-	Poe        bool    `json:"poe"`
-	Rx         float64 `json:"rx"`
-	Tx         float64 `json:"tx"`
+	Port             float64 `json:"id"`
+	LinkStatus       float64 `json:"linkStatus"`
+	LinkSpeed        float64 `json:"linkSpeed"`
+	Duplex           float64 `json:"duplex"`
+	PoePower         float64 `json:"poePower"`
+	Poe              bool    `json:"poe"`
+	PoeClass         float64 `json:"poeClass"`
+	PoeOverload      bool    `json:"poeOverload"`
+	Rx               float64 `json:"rx"`
+	Tx               float64 `json:"tx"`
+	RxPkts           float64 `json:"rxPkts"`
+	TxPkts           float64 `json:"txPkts"`
+	RxErrPkts        float64 `json:"rxErrPkts"`
+	TxErrPkts        float64 `json:"txErrPkts"`
+	RxDropPkts       float64 `json:"rxDropPkts"`
+	TxDropPkts       float64 `json:"txDropPkts"`
+	RxBroadPkts      float64 `json:"rxBroadPkts"`
+	TxBroadPkts      float64 `json:"txBroadPkts"`
+	RxMultiPkts      float64 `json:"rxMultiPkts"`
+	TxMultiPkts      float64 `json:"txMultiPkts"`
+	StpDiscarding    bool    `json:"stpDiscarding"`
+	LoopbackDetected bool    `json:"loopbackDetected"`
+	IsUplink         bool    `json:"isUplink"`
 }
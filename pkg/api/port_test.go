@@ -0,0 +1,46 @@
+package api
+
+import "testing"
+
+func TestPortStpState(t *testing.T) {
+	tests := []struct {
+		name   string
+		status portStatus
+		state  string
+	}{
+		{"forwarding", portStatus{LinkStatus: 1}, StpStateForwarding},
+		{"discarding", portStatus{LinkStatus: 1, StpDiscarding: true}, StpStateDiscarding},
+		{"link down", portStatus{LinkStatus: 0}, StpStateDisabled},
+		{"link down while discarding", portStatus{LinkStatus: 0, StpDiscarding: true}, StpStateDisabled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Port{PortStatus: tt.status}
+			if state := p.StpState(); state != tt.state {
+				t.Errorf("expected %s, got %s", tt.state, state)
+			}
+		})
+	}
+}
+
+func TestPortPoeStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		port   Port
+		status string
+	}{
+		{"disabled", Port{PoeMode: 0, PortStatus: portStatus{PoePower: 5}}, PoeStatusDisabled},
+		{"delivering", Port{PoeMode: 1, PortStatus: portStatus{PoePower: 5}}, PoeStatusDelivering},
+		{"searching", Port{PoeMode: 1}, PoeStatusSearching},
+		{"overload", Port{PoeMode: 1, PortStatus: portStatus{PoePower: 30, PoeOverload: true}}, PoeStatusOverload},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := tt.port.PoeStatus(); status != tt.status {
+				t.Errorf("expected %s, got %s", tt.status, status)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+	// the controller names time zones that the host running the exporter may not have installed
+	_ "time/tzdata"
+
+	log "github.com/rs/zerolog/log"
+)
+
+// GetSiteLocation returns the time zone set on a site, which the controller counts the days of the site in
+func (c *Client) GetSiteLocation(siteId string) (*time.Location, error) {
+	url := c.siteURL(siteId, "setting")
+	if c.openAPI() {
+		url = fmt.Sprintf("%s/openapi/v1/%s/sites/%s", c.Config.Host, c.omadaCID, siteId)
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.get(req, "site")
+	if err != nil {
+		return nil, err
+	}
+	log.Debug().Bytes("data", body).Msg("Received data from site endpoint")
+
+	// the web UI nests the site's settings, the OpenAPI returns them directly
+	sitedata := siteSettingResponse{}
+	err = json.Unmarshal(body, &sitedata)
+	if err != nil {
+		return nil, err
+	}
+	timeZone := sitedata.Result.TimeZone
+	if sitedata.Result.Site.TimeZone != "" {
+		timeZone = sitedata.Result.Site.TimeZone
+	}
+
+	return ParseTimeZone(timeZone)
+}
+
+type siteSettingResponse struct {
+	Result struct {
+		TimeZone string `json:"timeZone"`
+		Site     struct {
+			TimeZone string `json:"timeZone"`
+		} `json:"site"`
+	} `json:"result"`
+}
+
+var utcOffset = regexp.MustCompile(`(?:UTC|GMT)\s*([+-])(\d{1,2})(?::?(\d{2}))?`)
+
+// ParseTimeZone returns the location of a time zone reported by the controller, either a name like
+// Europe/London or an offset from UTC like UTC+08:00
+func ParseTimeZone(timeZone string) (*time.Location, error) {
+	if m := utcOffset.FindStringSubmatch(timeZone); m != nil {
+		hours, _ := strconv.Atoi(m[2])
+		minutes, _ := strconv.Atoi(m[3])
+		offset := hours*3600 + minutes*60
+		if m[1] == "-" {
+			offset = -offset
+		}
+		return time.FixedZone(timeZone, offset), nil
+	}
+	if timeZone == "" {
+		return nil, fmt.Errorf("no time zone reported")
+	}
+	return time.LoadLocation(timeZone)
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeZone(t *testing.T) {
+	tests := []struct {
+		timeZone string
+		offset   time.Duration
+		err      bool
+	}{
+		{"UTC", 0, false},
+		{"UTC+08:00", 8 * time.Hour, false},
+		{"UTC-03:30", -(3*time.Hour + 30*time.Minute), false},
+		{"UTC+5", 5 * time.Hour, false},
+		{"GMT-10", -10 * time.Hour, false},
+		{"(UTC+05:45) Kathmandu", 5*time.Hour + 45*time.Minute, false},
+		{"Asia/Tokyo", 9 * time.Hour, false},
+		{"", 0, true},
+		{"Nowhere/Special", 0, true},
+	}
+
+	// a date without daylight saving in any of the named zones
+	date := time.Date(2024, time.January, 15, 12, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.timeZone, func(t *testing.T) {
+			location, err := ParseTimeZone(tt.timeZone)
+			if tt.err {
+				if err == nil {
+					t.Errorf("expected an error, got %s", location)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if _, offset := date.In(location).Zone(); time.Duration(offset)*time.Second != tt.offset {
+				t.Errorf("expected an offset of %s, got %s", tt.offset, time.Duration(offset)*time.Second)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+
+	log "github.com/rs/zerolog/log"
+)
+
+// snapshot memoizes the responses to requests made between BeginSnapshot and EndSnapshot, so
+// that every collector reads a consistent view of the controller and endpoints shared between
+// collectors, like the device list, are only fetched once
+type snapshot struct {
+	mu        sync.Mutex
+	responses map[string]*snapshotResponse
+}
+
+type snapshotResponse struct {
+	once sync.Once
+	body []byte
+	err  error
+}
+
+// BeginSnapshot starts memoizing responses from the controller until EndSnapshot is called
+func (c *Client) BeginSnapshot() {
+	c.snapshotMu.Lock()
+	defer c.snapshotMu.Unlock()
+	c.snapshot = &snapshot{responses: map[string]*snapshotResponse{}}
+}
+
+// EndSnapshot stops memoizing responses, subsequent requests are sent to the controller
+func (c *Client) EndSnapshot() {
+	c.snapshotMu.Lock()
+	defer c.snapshotMu.Unlock()
+	c.snapshot = nil
+}
+
+// get sends a logged in request and returns the response body, reading it from the snapshot if one is in progress
+func (c *Client) get(req *http.Request, endpoint string) ([]byte, error) {
+	c.snapshotMu.Lock()
+	s := c.snapshot
+	c.snapshotMu.Unlock()
+
+	if s == nil {
+		return c.fetch(req, endpoint)
+	}
+
+	s.mu.Lock()
+	res, ok := s.responses[req.URL.String()]
+	if !ok {
+		res = &snapshotResponse{}
+		s.responses[req.URL.String()] = res
+	}
+	s.mu.Unlock()
+
+	res.once.Do(func() {
+		res.body, res.err = c.fetch(req, endpoint)
+	})
+	return res.body, res.err
+}
+
+// fetch sends a logged in request, retrying it once if the controller rejected the credentials it was sent with
+func (c *Client) fetch(req *http.Request, endpoint string) ([]byte, error) {
+	body, err := c.fetchOnce(req, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	res := struct {
+		ErrorCode int `json:"errorCode"`
+	}{}
+	if json.Unmarshal(body, &res) == nil && c.auth.expired(req, res.ErrorCode) {
+		log.Warn().Str("endpoint", endpoint).Int("error_code", res.ErrorCode).Msg("Controller rejected the credentials, retrying")
+		return c.fetchOnce(req, endpoint)
+	}
+	return body, nil
+}
+
+func (c *Client) fetchOnce(req *http.Request, endpoint string) ([]byte, error) {
+	resp, err := c.makeLoggedInRequest(req, endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
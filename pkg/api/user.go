@@ -3,45 +3,120 @@ package api
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 )
 
+// Site is an Omada site visible to the configured user
+type Site struct {
+	Name string
+	Id   string
+}
+
 // there's no nice way of fetching the site ID from the `Viewer` role
 // calling the user endpoint seems to return a list of sites for the user
-func (c *Client) getSiteId(name string) (*string, error) {
+func (c *Client) GetSites() ([]Site, error) {
+	if c.openAPI() {
+		return c.getOpenAPISites()
+	}
+
 	url := fmt.Sprintf("%s/%s/api/v2/users/current", c.Config.Host, c.omadaCID)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := c.makeLoggedInRequest(req)
+	body, err := c.get(req, "users/current")
 	if err != nil {
 		return nil, err
 	}
 
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	user := userResponse{}
+	err = json.Unmarshal(body, &user)
 	if err != nil {
 		return nil, err
 	}
 
-	user := userResponse{}
-	err = json.Unmarshal(body, &user)
+	sites := []Site{}
+	for _, s := range user.Result.Privilege.Sites {
+		sites = append(sites, Site{Name: s.Key, Id: s.Value})
+	}
+
+	return sites, nil
+}
+
+// the OpenAPI client isn't a user, but has an endpoint to list the sites it has access to
+func (c *Client) getOpenAPISites() ([]Site, error) {
+	url := fmt.Sprintf("%s/openapi/v1/%s/sites", c.Config.Host, c.omadaCID)
+	openAPISites, err := getPages[openAPISite](c, url, nil, 1000, "sites")
 	if err != nil {
 		return nil, err
 	}
 
-	for _, s := range user.Result.Privilege.Sites {
-		if s.Key == name {
-			return &s.Value, nil
+	sites := []Site{}
+	for _, s := range openAPISites {
+		sites = append(sites, Site{Name: s.Name, Id: s.SiteId})
+	}
+
+	return sites, nil
+}
+
+func (c *Client) getSiteId(name string) (*string, error) {
+	sites, err := c.GetSites()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range sites {
+		if s.Name == name {
+			return &s.Id, nil
 		}
 	}
 
 	return nil, fmt.Errorf("failed to find site with name %s", name)
 }
 
+// resolves the sites to scrape, either the single configured site or every site
+// the user can see, filtered by the include and exclude lists
+func (c *Client) getConfiguredSites() ([]Site, error) {
+	if !c.Config.AllSites {
+		sid, err := c.getSiteId(c.Config.Site)
+		if err != nil {
+			return nil, err
+		}
+		return []Site{{Name: c.Config.Site, Id: *sid}}, nil
+	}
+
+	sites, err := c.GetSites()
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := []Site{}
+	for _, s := range sites {
+		if len(c.Config.IncludeSites) > 0 && !contains(c.Config.IncludeSites, s.Name) {
+			continue
+		}
+		if contains(c.Config.ExcludeSites, s.Name) {
+			continue
+		}
+		filtered = append(filtered, s)
+	}
+	if len(filtered) == 0 {
+		return nil, fmt.Errorf("no sites left to scrape after applying include and exclude lists")
+	}
+
+	return filtered, nil
+}
+
+func contains(list []string, name string) bool {
+	for _, item := range list {
+		if item == name {
+			return true
+		}
+	}
+	return false
+}
+
 type userResponse struct {
 	Result user `json:"result"`
 }
@@ -58,3 +133,8 @@ type site struct {
 	Key   string `json:"name"`
 	Value string `json:"key"`
 }
+
+type openAPISite struct {
+	SiteId string `json:"siteId"`
+	Name   string `json:"name"`
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	log "github.com/rs/zerolog/log"
+)
+
+func (c *Client) GetWlans(siteId string) ([]Wlan, error) {
+	path := "setting/wlans"
+	if c.openAPI() {
+		path = "wireless-network/wlans"
+	}
+	url := c.siteURL(siteId, path)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.get(req, "wlans")
+	if err != nil {
+		return nil, err
+	}
+	log.Debug().Bytes("data", body).Msg("Received data from wlans endpoint")
+
+	wlandata := wlanResponse{}
+	err = json.Unmarshal(body, &wlandata)
+
+	return wlandata.Result, err
+}
+
+func (c *Client) GetSsids(siteId string, wlanId string) ([]Ssid, error) {
+	path := fmt.Sprintf("setting/wlans/%s/ssids", wlanId)
+	if c.openAPI() {
+		path = fmt.Sprintf("wireless-network/wlans/%s/ssids", wlanId)
+	}
+	return getPages[Ssid](c, c.siteURL(siteId, path), nil, 1000, "ssids")
+}
+
+type wlanResponse struct {
+	Result listResult[Wlan] `json:"result"`
+}
+type Wlan struct {
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	Primary bool   `json:"primary"`
+}
+
+type Ssid struct {
+	Id         string  `json:"id"`
+	Name       string  `json:"name"`
+	Band       int     `json:"band"`
+	Broadcast  bool    `json:"broadcast"`
+	Security   int     `json:"security"`
+	VlanEnable bool    `json:"vlanEnable"`
+	VlanId     float64 `json:"vlanId"`
+	Enable     *bool   `json:"enable"`
+}
+
+// Enabled returns whether the SSID is enabled, controllers that can't disable SSIDs don't report it
+func (s *Ssid) Enabled() bool {
+	return s.Enable == nil || *s.Enable
+}
+
+// Bands returns the radio bands the SSID is broadcast on, the omada API reports them as a bitmask
+func (s *Ssid) Bands() []string {
+	result := []string{}
+	if s.Band&1 != 0 {
+		result = append(result, bands[0])
+	}
+	if s.Band&2 != 0 {
+		result = append(result, bands[1])
+	}
+	if s.Band&4 != 0 {
+		result = append(result, bands[3])
+	}
+	return result
+}
+
+// SecurityMode returns the name of the SSID's security mode
+func (s *Ssid) SecurityMode() string {
+	mapping := map[int]string{
+		0: "none",
+		2: "wpa-enterprise",
+		3: "wpa-personal",
+		4: "ppsk-without-radius",
+		5: "ppsk-with-radius",
+	}
+	formatted, ok := mapping[s.Security]
+	if !ok {
+		return ""
+	}
+	return formatted
+}
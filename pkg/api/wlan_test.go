@@ -0,0 +1,29 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSsidBands(t *testing.T) {
+	tests := []struct {
+		band  int
+		bands string
+	}{
+		{0, ""},
+		{1, "2.4GHz"},
+		{2, "5GHz"},
+		{3, "2.4GHz,5GHz"},
+		{4, "6GHz"},
+		{7, "2.4GHz,5GHz,6GHz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.bands, func(t *testing.T) {
+			s := Ssid{Band: tt.band}
+			if bands := strings.Join(s.Bands(), ","); bands != tt.bands {
+				t.Errorf("expected %q for %d, got %q", tt.bands, tt.band, bands)
+			}
+		})
+	}
+}
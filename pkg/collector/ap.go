@@ -0,0 +1,165 @@
+package collector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charlie-haley/omada_exporter/pkg/api"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type apCollector struct {
+	omadaApRadioChannel          *prometheus.Desc
+	omadaApRadioChannelWidthMhz  *prometheus.Desc
+	omadaApRadioTxPowerDbm       *prometheus.Desc
+	omadaApRadioChannelUtilPct   *prometheus.Desc
+	omadaApRadioClients          *prometheus.Desc
+	omadaApRadioRxBytes          *prometheus.Desc
+	omadaApRadioTxBytes          *prometheus.Desc
+	omadaApRadioRxRetryPackets   *prometheus.Desc
+	omadaApRadioTxRetryPackets   *prometheus.Desc
+	omadaApRadioRxDroppedPackets *prometheus.Desc
+	omadaApRadioTxDroppedPackets *prometheus.Desc
+	client                       *api.Client
+}
+
+func (c *apCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.omadaApRadioChannel
+	ch <- c.omadaApRadioChannelWidthMhz
+	ch <- c.omadaApRadioTxPowerDbm
+	ch <- c.omadaApRadioChannelUtilPct
+	ch <- c.omadaApRadioClients
+	ch <- c.omadaApRadioRxBytes
+	ch <- c.omadaApRadioTxBytes
+	ch <- c.omadaApRadioRxRetryPackets
+	ch <- c.omadaApRadioTxRetryPackets
+	ch <- c.omadaApRadioRxDroppedPackets
+	ch <- c.omadaApRadioTxDroppedPackets
+}
+
+func (c *apCollector) Update(ch chan<- prometheus.Metric) error {
+	client := c.client
+
+	var failed error
+	for _, site := range client.Sites {
+		devices, err := client.GetDeviceList(site.Id)
+		if err != nil {
+			failed = fmt.Errorf("failed to get devices for site %s: %s", site.Name, err)
+			continue
+		}
+
+		for _, device := range devices {
+			if device.Type != "ap" {
+				continue
+			}
+
+			ap, err := client.GetAccessPoint(site.Id, device.Mac)
+			if err != nil {
+				failed = fmt.Errorf("failed to get access point %s: %s", device.Mac, err)
+				continue
+			}
+
+			for _, radio := range ap.Radios() {
+				labels := []string{device.Name, device.Mac, radio.Band, site.Name, site.Id}
+
+				if channel, ok := parseLeadingNumber(radio.Settings.ActualChannel); ok {
+					ch <- prometheus.MustNewConstMetric(c.omadaApRadioChannel, prometheus.GaugeValue, channel, labels...)
+				}
+				if width, ok := parseLeadingNumber(radio.Settings.BandWidth); ok {
+					ch <- prometheus.MustNewConstMetric(c.omadaApRadioChannelWidthMhz, prometheus.GaugeValue, width, labels...)
+				}
+				ch <- prometheus.MustNewConstMetric(c.omadaApRadioTxPowerDbm, prometheus.GaugeValue, radio.Settings.TxPower, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaApRadioChannelUtilPct, prometheus.GaugeValue, radio.Settings.BusyUtil, append(labels, "busy")...)
+				ch <- prometheus.MustNewConstMetric(c.omadaApRadioChannelUtilPct, prometheus.GaugeValue, radio.Settings.RxUtil, append(labels, "rx")...)
+				ch <- prometheus.MustNewConstMetric(c.omadaApRadioChannelUtilPct, prometheus.GaugeValue, radio.Settings.TxUtil, append(labels, "tx")...)
+				ch <- prometheus.MustNewConstMetric(c.omadaApRadioChannelUtilPct, prometheus.GaugeValue, radio.Settings.InterUtil, append(labels, "interference")...)
+				ch <- prometheus.MustNewConstMetric(c.omadaApRadioClients, prometheus.GaugeValue, radio.Clients, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaApRadioRxBytes, prometheus.CounterValue, radio.Traffic.Rx, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaApRadioTxBytes, prometheus.CounterValue, radio.Traffic.Tx, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaApRadioRxRetryPackets, prometheus.CounterValue, radio.Traffic.RxRetryPkts, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaApRadioTxRetryPackets, prometheus.CounterValue, radio.Traffic.TxRetryPkts, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaApRadioRxDroppedPackets, prometheus.CounterValue, radio.Traffic.RxDropPkts, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaApRadioTxDroppedPackets, prometheus.CounterValue, radio.Traffic.TxDropPkts, labels...)
+			}
+		}
+	}
+
+	return failed
+}
+
+// the omada API returns channels as e.g. "36 / 5180MHz" and widths as e.g. "80MHz"
+func parseLeadingNumber(s string) (float64, bool) {
+	end := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if end < 0 {
+		end = len(s)
+	}
+	n, err := strconv.Atoi(s[:end])
+	if err != nil {
+		return 0, false
+	}
+	return float64(n), true
+}
+
+func NewApCollector(c *api.Client) *apCollector {
+	labels := []string{"ap_name", "mac", "band", "site", "site_id"}
+
+	return &apCollector{
+		omadaApRadioChannel: prometheus.NewDesc("omada_ap_radio_channel",
+			"The channel the radio is using.",
+			labels,
+			nil,
+		),
+		omadaApRadioChannelWidthMhz: prometheus.NewDesc("omada_ap_radio_channel_width_mhz",
+			"The channel width of the radio in MHz.",
+			labels,
+			nil,
+		),
+		omadaApRadioTxPowerDbm: prometheus.NewDesc("omada_ap_radio_tx_power_dbm",
+			"The transmit power of the radio in dBm.",
+			labels,
+			nil,
+		),
+		omadaApRadioChannelUtilPct: prometheus.NewDesc("omada_ap_radio_channel_utilization_pct",
+			"Utilization of the radio's channel in percent, by busy, rx, tx and interference.",
+			append(labels, "type"),
+			nil,
+		),
+		omadaApRadioClients: prometheus.NewDesc("omada_ap_radio_clients",
+			"Number of clients connected to the radio.",
+			labels,
+			nil,
+		),
+		omadaApRadioRxBytes: prometheus.NewDesc("omada_ap_radio_rx_bytes",
+			"Bytes received by the radio.",
+			labels,
+			nil,
+		),
+		omadaApRadioTxBytes: prometheus.NewDesc("omada_ap_radio_tx_bytes",
+			"Bytes transmitted by the radio.",
+			labels,
+			nil,
+		),
+		omadaApRadioRxRetryPackets: prometheus.NewDesc("omada_ap_radio_rx_retry_packets",
+			"Packets retried on receive by the radio.",
+			labels,
+			nil,
+		),
+		omadaApRadioTxRetryPackets: prometheus.NewDesc("omada_ap_radio_tx_retry_packets",
+			"Packets retried on transmit by the radio.",
+			labels,
+			nil,
+		),
+		omadaApRadioRxDroppedPackets: prometheus.NewDesc("omada_ap_radio_rx_dropped_packets",
+			"Received packets dropped by the radio.",
+			labels,
+			nil,
+		),
+		omadaApRadioTxDroppedPackets: prometheus.NewDesc("omada_ap_radio_tx_dropped_packets",
+			"Transmitted packets dropped by the radio.",
+			labels,
+			nil,
+		),
+		client: c,
+	}
+}
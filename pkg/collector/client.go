@@ -2,13 +2,19 @@ package collector
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/charlie-haley/omada_exporter/pkg/api"
+	"github.com/charlie-haley/omada_exporter/pkg/config"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/rs/zerolog/log"
 )
 
 type clientCollector struct {
+	omadaClientInfo                  *prometheus.Desc
 	omadaClientDownloadActivityBytes *prometheus.Desc
 	omadaClientSignalPct             *prometheus.Desc
 	omadaClientSignalNoiseDbm        *prometheus.Desc
@@ -17,11 +23,32 @@ type clientCollector struct {
 	omadaClientTrafficUp             *prometheus.Desc
 	omadaClientTxRate                *prometheus.Desc
 	omadaClientRxRate                *prometheus.Desc
+	omadaClientUptimeSeconds         *prometheus.Desc
+	omadaClientConnectedSince        *prometheus.Desc
+	omadaClientFirstSeen             *prometheus.Desc
+	omadaClientsDistinctToday        *prometheus.Desc
+	omadaClientSessionsEndedToday    *prometheus.Desc
+	omadaClientSessionsSkippedToday  *prometheus.Desc
 	omadaClientConnectedTotal        *prometheus.Desc
+	omadaClientRoamsTotal            *prometheus.Desc
+	omadaApRoamInTotal               *prometheus.Desc
+	omadaApRoamOutTotal              *prometheus.Desc
+	omadaClientSeriesOverflow        *prometheus.Desc
+	omadaClientGroupClients          *prometheus.Desc
+	omadaClientGroupTrafficDown      *prometheus.Desc
+	omadaClientGroupTrafficUp        *prometheus.Desc
+	labels                           []string
+	infoLabels                       []string
+	roams                            *RoamTracker
+	mu                               sync.Mutex
+	overflow                         map[string]float64
+	historyMu                        sync.Mutex
+	history                          map[string]*clientHistory
 	client                           *api.Client
 }
 
 func (c *clientCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.omadaClientInfo
 	ch <- c.omadaClientDownloadActivityBytes
 	ch <- c.omadaClientSignalPct
 	ch <- c.omadaClientSignalNoiseDbm
@@ -30,7 +57,81 @@ func (c *clientCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.omadaClientTrafficUp
 	ch <- c.omadaClientTxRate
 	ch <- c.omadaClientRxRate
+	ch <- c.omadaClientUptimeSeconds
+	ch <- c.omadaClientConnectedSince
+	ch <- c.omadaClientFirstSeen
+	ch <- c.omadaClientsDistinctToday
+	ch <- c.omadaClientSessionsEndedToday
+	ch <- c.omadaClientSessionsSkippedToday
 	ch <- c.omadaClientConnectedTotal
+	ch <- c.omadaClientRoamsTotal
+	ch <- c.omadaApRoamInTotal
+	ch <- c.omadaApRoamOutTotal
+	ch <- c.omadaClientSeriesOverflow
+	ch <- c.omadaClientGroupClients
+	ch <- c.omadaClientGroupTrafficDown
+	ch <- c.omadaClientGroupTrafficUp
+}
+
+// clientGroupKey is an AP, SSID or VLAN that clients are rolled up into with --clients.aggregate-only
+type clientGroupKey struct {
+	group string
+	name  string
+}
+
+type clientGroup struct {
+	clients     float64
+	trafficDown float64
+	trafficUp   float64
+}
+
+// addClientGroups adds a client to the rollups of its AP and SSID when it's wireless, and of its VLAN
+func addClientGroups(groups map[clientGroupKey]*clientGroup, item api.NetworkClient, vlanId string) {
+	keys := []clientGroupKey{{"vlan", vlanId}}
+	if item.Wireless {
+		keys = append(keys, clientGroupKey{"ap", item.ApName}, clientGroupKey{"ssid", item.Ssid})
+	}
+	for _, key := range keys {
+		group, ok := groups[key]
+		if !ok {
+			group = &clientGroup{}
+			groups[key] = group
+		}
+		group.clients += 1
+		group.trafficDown += item.TrafficDown
+		group.trafficUp += item.TrafficUp
+	}
+}
+
+// clientLabelValues returns the value of each of the labels
+func clientLabelValues(labels []string, values map[string]string) []string {
+	result := make([]string, len(labels))
+	for i, label := range labels {
+		result[i] = values[label]
+	}
+	return result
+}
+
+// withLabels returns labels with each of extra that it's missing appended
+func withLabels(labels []string, extra ...string) []string {
+	result := append([]string{}, labels...)
+	for _, label := range extra {
+		found := false
+		for _, l := range labels {
+			if l == label {
+				found = true
+			}
+		}
+		if !found {
+			result = append(result, label)
+		}
+	}
+	return result
+}
+
+// normalizeMac formats a MAC address the way the controller reports it, e.g. AA-BB-CC-DD-EE-FF
+func normalizeMac(mac string) string {
+	return strings.ToUpper(strings.ReplaceAll(mac, ":", "-"))
 }
 
 func FormatWifiMode(wifiMode int) string {
@@ -51,68 +152,236 @@ func FormatWifiMode(wifiMode int) string {
 	return formatted
 }
 
-func (c *clientCollector) Collect(ch chan<- prometheus.Metric) {
+func (c *clientCollector) Update(ch chan<- prometheus.Metric) error {
 	client := c.client
-	config := c.client.Config
 
-	site := config.Site
-	clients, err := client.GetClients()
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to get clients")
-		return
-	}
+	var failed error
+	for _, site := range client.Sites {
+		clients, err := client.GetClients(site.Id)
+		if err != nil {
+			failed = fmt.Errorf("failed to get clients for site %s: %s", site.Name, err)
+			continue
+		}
 
-	totals := map[string]int{}
+		totals := map[string]int{}
+		now := time.Now()
 
-	for _, item := range clients {
-		vlanId := fmt.Sprintf("%.0f", item.VlanId)
-		port := fmt.Sprintf("%.0f", item.Port)
+		allowed := map[string]bool{}
+		for _, mac := range client.Config.ClientAllowMacs {
+			allowed[normalizeMac(mac)] = true
+		}
+		groups := map[clientGroupKey]*clientGroup{}
+		series, overflow := 0, 0
+		// sorted so --clients.max-series keeps the same clients on every scrape, whatever order the controller returns
+		sort.Slice(clients, func(i, j int) bool {
+			return normalizeMac(clients[i].Mac) < normalizeMac(clients[j].Mac)
+		})
+
+		for _, item := range clients {
+			vlanId := fmt.Sprintf("%.0f", item.VlanId)
+
+			connectionMode, wifiMode, port := "wired", "", fmt.Sprintf("%.0f", item.Port)
+			if item.Wireless {
+				connectionMode, wifiMode, port = "wireless", FormatWifiMode(int(item.WifiMode)), ""
+				totals[wifiMode] += 1
+			} else {
+				totals["wired"] += 1
+			}
+
+			if client.Config.ClientAggregateOnly {
+				addClientGroups(groups, item, vlanId)
+			}
 
-		if item.Wireless {
-			wifiMode := FormatWifiMode(int(item.WifiMode))
+			// clients on the allowlist always get their own series and don't count towards the limit
+			if !allowed[normalizeMac(item.Mac)] {
+				if client.Config.ClientAggregateOnly {
+					continue
+				}
+				if client.Config.ClientMaxSeries > 0 && series >= client.Config.ClientMaxSeries {
+					overflow += 1
+					continue
+				}
+				series += 1
+			}
+
+			values := map[string]string{
+				"client": item.Name, "vendor": item.Vendor, "ip": item.Ip, "mac": item.Mac, "host_name": item.HostName,
+				"site": site.Name, "site_id": site.Id, "connection_mode": connectionMode, "wifi_mode": wifiMode,
+				"ap_name": item.ApName, "ssid": item.Ssid, "vlan_id": vlanId,
+				"switch_port": port, "switch_name": item.SwitchName, "switch_mac": item.SwitchMac,
+			}
+			labels := clientLabelValues(c.labels, values)
+			if infoMetrics(client) {
+				ch <- prometheus.MustNewConstMetric(c.omadaClientInfo, prometheus.GaugeValue, 1, clientLabelValues(c.infoLabels, values)...)
+			}
 
-			CollectWirelessMetrics := func(desc *prometheus.Desc, valueType prometheus.ValueType, value float64) {
-				ch <- prometheus.MustNewConstMetric(desc, valueType, value,
-					item.Name, item.Vendor, item.Ip, item.Mac, item.HostName, site, client.SiteId, "wireless", wifiMode, item.ApName, item.Ssid, vlanId)
+			ch <- prometheus.MustNewConstMetric(c.omadaClientDownloadActivityBytes, prometheus.GaugeValue, item.Activity, labels...)
+			ch <- prometheus.MustNewConstMetric(c.omadaClientTrafficDown, prometheus.CounterValue, item.TrafficDown, labels...)
+			ch <- prometheus.MustNewConstMetric(c.omadaClientTrafficUp, prometheus.CounterValue, item.TrafficUp, labels...)
+			ch <- prometheus.MustNewConstMetric(c.omadaClientTxRate, prometheus.GaugeValue, item.TxRate, labels...)
+			ch <- prometheus.MustNewConstMetric(c.omadaClientRxRate, prometheus.GaugeValue, item.RxRate, labels...)
+			ch <- prometheus.MustNewConstMetric(c.omadaClientUptimeSeconds, prometheus.GaugeValue, item.Uptime, labels...)
+			ch <- prometheus.MustNewConstMetric(c.omadaClientConnectedSince, prometheus.GaugeValue, item.ConnectedSince(now), labels...)
+			if item.FirstSeen > 0 {
+				ch <- prometheus.MustNewConstMetric(c.omadaClientFirstSeen, prometheus.GaugeValue, item.FirstSeen/1000, labels...)
+			}
+			if item.Wireless {
+				ch <- prometheus.MustNewConstMetric(c.omadaClientSignalPct, prometheus.GaugeValue, item.SignalLevel, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaClientSignalNoiseDbm, prometheus.GaugeValue, item.SignalNoise, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaClientRssiDbm, prometheus.GaugeValue, item.Rssi, labels...)
 			}
-			CollectWirelessMetrics(c.omadaClientSignalPct, prometheus.GaugeValue, item.SignalLevel)
-			CollectWirelessMetrics(c.omadaClientSignalNoiseDbm, prometheus.GaugeValue, item.SignalNoise)
-			CollectWirelessMetrics(c.omadaClientRssiDbm, prometheus.GaugeValue, item.Rssi)
-			CollectWirelessMetrics(c.omadaClientTrafficDown, prometheus.CounterValue, item.TrafficDown)
-			CollectWirelessMetrics(c.omadaClientTrafficUp, prometheus.CounterValue, item.TrafficUp)
-			CollectWirelessMetrics(c.omadaClientTxRate, prometheus.GaugeValue, item.TxRate)
-			CollectWirelessMetrics(c.omadaClientRxRate, prometheus.GaugeValue, item.RxRate)
-
-			totals[wifiMode] += 1
-			ch <- prometheus.MustNewConstMetric(c.omadaClientDownloadActivityBytes, prometheus.GaugeValue, item.Activity,
-				item.Name, item.Vendor, item.Ip, item.Mac, item.HostName, site, client.SiteId, "wireless", wifiMode, item.ApName, item.Ssid, vlanId, "")
 		}
-		if !item.Wireless {
-			totals["wired"] += 1
-			ch <- prometheus.MustNewConstMetric(c.omadaClientDownloadActivityBytes, prometheus.GaugeValue, item.Activity,
-				item.Name, item.Vendor, item.Ip, item.Mac, item.HostName, site, client.SiteId, "wired", "", "", "", vlanId, port)
+
+		for k, v := range groups {
+			ch <- prometheus.MustNewConstMetric(c.omadaClientGroupClients, prometheus.GaugeValue, v.clients, k.group, k.name, site.Name, site.Id)
+			ch <- prometheus.MustNewConstMetric(c.omadaClientGroupTrafficDown, prometheus.GaugeValue, v.trafficDown, k.group, k.name, site.Name, site.Id)
+			ch <- prometheus.MustNewConstMetric(c.omadaClientGroupTrafficUp, prometheus.GaugeValue, v.trafficUp, k.group, k.name, site.Name, site.Id)
 		}
+
+		c.mu.Lock()
+		c.overflow[site.Id] += float64(overflow)
+		ch <- prometheus.MustNewConstMetric(c.omadaClientSeriesOverflow, prometheus.CounterValue, c.overflow[site.Id], site.Name, site.Id)
+		c.mu.Unlock()
+
+		c.roams.observe(site, clients, now)
+
+		if client.Config.ClientHistory {
+			err := c.updateHistory(ch, site, clients)
+			if err != nil {
+				failed = fmt.Errorf("failed to get past clients for site %s: %s", site.Name, err)
+			}
+		}
+
+		for connectionModeFmt, v := range totals {
+			if connectionModeFmt == "wired" {
+				ch <- prometheus.MustNewConstMetric(c.omadaClientConnectedTotal, prometheus.GaugeValue, float64(v),
+					site.Name, site.Id, "wired", "")
+			} else {
+				ch <- prometheus.MustNewConstMetric(c.omadaClientConnectedTotal, prometheus.GaugeValue, float64(v),
+					site.Name, site.Id, "wireless", connectionModeFmt)
+			}
+		}
+	}
+
+	c.updateRoams(ch)
+
+	return failed
+}
+
+// updateRoams reports the roams between each pair of APs, and the roams into and out of each AP
+func (c *clientCollector) updateRoams(ch chan<- prometheus.Metric) {
+	type apKey struct {
+		ap     string
+		site   string
+		siteId string
 	}
+	in, out := map[apKey]float64{}, map[apKey]float64{}
+	for k, v := range c.roams.roams() {
+		ch <- prometheus.MustNewConstMetric(c.omadaClientRoamsTotal, prometheus.CounterValue, v, k.from, k.to, k.site, k.siteId)
+		in[apKey{k.to, k.site, k.siteId}] += v
+		out[apKey{k.from, k.site, k.siteId}] += v
+	}
+	for k, v := range in {
+		ch <- prometheus.MustNewConstMetric(c.omadaApRoamInTotal, prometheus.CounterValue, v, k.ap, k.site, k.siteId)
+	}
+	for k, v := range out {
+		ch <- prometheus.MustNewConstMetric(c.omadaApRoamOutTotal, prometheus.CounterValue, v, k.ap, k.site, k.siteId)
+	}
+}
 
-	for connectionModeFmt, v := range totals {
-		if connectionModeFmt == "wired" {
-			ch <- prometheus.MustNewConstMetric(c.omadaClientConnectedTotal, prometheus.GaugeValue, float64(v),
-				site, client.SiteId, "wired", "")
-		} else {
-			ch <- prometheus.MustNewConstMetric(c.omadaClientConnectedTotal, prometheus.GaugeValue, float64(v),
-				site, client.SiteId, "wireless", connectionModeFmt)
+// clientHistory is the past connections of a site that ended so far today, so each scrape only fetches
+// the connections that ended since the previous one
+type clientHistory struct {
+	location *time.Location
+	midnight time.Time
+	fetched  time.Time
+	macs     map[string]bool
+	sessions map[pastSessionKey]bool
+	skipped  int
+}
+
+// pastSessionKey identifies a past connection, which may be returned by two fetches ending and starting at the same time
+type pastSessionKey struct {
+	mac       string
+	firstSeen float64
+}
+
+// updateHistory counts the distinct clients seen since midnight, both connected and past, and the sessions that ended
+// since midnight. Midnight is in the site's time zone by the controller's clock, which the controller counts days in.
+func (c *clientCollector) updateHistory(ch chan<- prometheus.Metric, site api.Site, clients []api.NetworkClient) error {
+	c.historyMu.Lock()
+	defer c.historyMu.Unlock()
+
+	h, ok := c.history[site.Id]
+	if !ok {
+		location, err := c.client.GetSiteLocation(site.Id)
+		if err != nil {
+			log.Warn().Err(err).Str("site", site.Name).Msg("Failed to get the time zone of the site, counting days in the exporter's time zone")
+			location = time.Local
 		}
+		h = &clientHistory{location: location}
+		c.history[site.Id] = h
 	}
+
+	now := c.client.Now().In(h.location)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, h.location)
+	if !h.midnight.Equal(midnight) {
+		h.midnight, h.fetched = midnight, midnight
+		h.macs, h.sessions, h.skipped = map[string]bool{}, map[pastSessionKey]bool{}, 0
+	}
+
+	pastClients, skipped, err := c.client.GetPastClients(site.Id, h.fetched, now)
+	if err != nil {
+		return err
+	}
+	h.skipped += skipped
+	for _, item := range pastClients {
+		h.macs[item.Mac] = true
+		h.sessions[pastSessionKey{item.Mac, item.FirstSeen}] = true
+	}
+	h.fetched = now
+
+	distinct := map[string]bool{}
+	for _, item := range clients {
+		distinct[item.Mac] = true
+	}
+	for mac := range h.macs {
+		distinct[mac] = true
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.omadaClientsDistinctToday, prometheus.GaugeValue, float64(len(distinct)), site.Name, site.Id)
+	ch <- prometheus.MustNewConstMetric(c.omadaClientSessionsEndedToday, prometheus.GaugeValue, float64(len(h.sessions)), site.Name, site.Id)
+	ch <- prometheus.MustNewConstMetric(c.omadaClientSessionsSkippedToday, prometheus.GaugeValue, float64(h.skipped), site.Name, site.Id)
+	return nil
 }
 
-func NewClientCollector(c *api.Client) *clientCollector {
-	client_labels := []string{"client", "vendor", "ip", "mac", "host_name", "site", "site_id", "connection_mode", "wifi_mode", "ap_name", "ssid", "vlan_id"}
-	wired_client_labels := append(client_labels, "switch_port")
+// NewClientCollector returns the client collector, counting roams with roams, or a new tracker if it's nil
+func NewClientCollector(c *api.Client, roams *RoamTracker) *clientCollector {
+	if roams == nil {
+		roams = NewRoamTracker()
+	}
+	client_labels := config.ClientLabels
+	if c != nil && len(c.Config.ClientLabels) > 0 {
+		client_labels = c.Config.ClientLabels
+	}
+	info_labels := client_labels
+	if infoMetrics(c) {
+		client_labels = []string{"mac", "site_id"}
+		// the info metric is joined on mac and site_id, so it keeps them even when --clients.labels drops site_id
+		info_labels = withLabels(info_labels, client_labels...)
+	}
+	group_labels := []string{"group", "name", "site", "site_id"}
 
 	return &clientCollector{
+		omadaClientInfo: prometheus.NewDesc("omada_client_info",
+			"Metadata of the client, always 1. Only with --info-metrics, which removes these labels from the other client metrics.",
+			info_labels,
+			nil,
+		),
+
 		omadaClientDownloadActivityBytes: prometheus.NewDesc("omada_client_download_activity_bytes",
 			"The current download activity for the client in bytes.",
-			wired_client_labels,
+			client_labels,
 			nil,
 		),
 
@@ -135,35 +404,118 @@ func NewClientCollector(c *api.Client) *clientCollector {
 		),
 
 		omadaClientTrafficDown: prometheus.NewDesc("omada_client_traffic_down_bytes",
-			"Total bytes received by wireless client.",
+			"Total bytes received by the client.",
 			client_labels,
 			nil,
 		),
 
 		omadaClientTrafficUp: prometheus.NewDesc("omada_client_traffic_up_bytes",
-			"Total bytes sent by wireless client.",
+			"Total bytes sent by the client.",
 			client_labels,
 			nil,
 		),
 
 		omadaClientTxRate: prometheus.NewDesc("omada_client_tx_rate",
-			"TX rate of wireless client.",
+			"TX rate of the client.",
 			client_labels,
 			nil,
 		),
 
 		omadaClientRxRate: prometheus.NewDesc("omada_client_rx_rate",
-			"RX rate of wireless client.",
+			"RX rate of the client.",
+			client_labels,
+			nil,
+		),
+
+		omadaClientUptimeSeconds: prometheus.NewDesc("omada_client_uptime_seconds",
+			"How long the client has been connected for.",
 			client_labels,
 			nil,
 		),
 
+		omadaClientConnectedSince: prometheus.NewDesc("omada_client_connected_since_timestamp_seconds",
+			"The time the client connected, as a unix timestamp.",
+			client_labels,
+			nil,
+		),
+
+		omadaClientFirstSeen: prometheus.NewDesc("omada_client_first_seen_timestamp_seconds",
+			"The time the controller first saw the client, as a unix timestamp.",
+			client_labels,
+			nil,
+		),
+
+		omadaClientsDistinctToday: prometheus.NewDesc("omada_clients_distinct_today",
+			"Number of distinct clients connected to the site since midnight in the site's time zone, only with --clients.history.",
+			[]string{"site", "site_id"},
+			nil,
+		),
+
+		omadaClientSessionsEndedToday: prometheus.NewDesc("omada_client_sessions_ended_today",
+			"Number of client connections to the site that ended since midnight in the site's time zone, only with --clients.history.",
+			[]string{"site", "site_id"},
+			nil,
+		),
+
+		omadaClientSessionsSkippedToday: prometheus.NewDesc("omada_client_sessions_skipped_today",
+			"Number of client connections to the site that ended since midnight but weren't fetched because too many ended between scrapes, only with --clients.history.",
+			[]string{"site", "site_id"},
+			nil,
+		),
+
 		omadaClientConnectedTotal: prometheus.NewDesc("omada_client_connected_total",
 			"Total number of connected clients.",
 			[]string{"site", "site_id", "connection_mode", "wifi_mode"},
 			nil,
 		),
 
-		client: c,
+		omadaClientRoamsTotal: prometheus.NewDesc("omada_client_roams_total",
+			"Number of times a wireless client moved from one AP to another since the exporter started.",
+			[]string{"from_ap", "to_ap", "site", "site_id"},
+			nil,
+		),
+
+		omadaApRoamInTotal: prometheus.NewDesc("omada_ap_roam_in_total",
+			"Number of times a wireless client moved to the AP from another AP since the exporter started.",
+			[]string{"ap_name", "site", "site_id"},
+			nil,
+		),
+
+		omadaApRoamOutTotal: prometheus.NewDesc("omada_ap_roam_out_total",
+			"Number of times a wireless client moved from the AP to another AP since the exporter started.",
+			[]string{"ap_name", "site", "site_id"},
+			nil,
+		),
+
+		omadaClientSeriesOverflow: prometheus.NewDesc("omada_client_series_overflow_total",
+			"Number of times a client was left out of the per-client metrics by --clients.max-series.",
+			[]string{"site", "site_id"},
+			nil,
+		),
+
+		omadaClientGroupClients: prometheus.NewDesc("omada_client_group_clients",
+			"Number of clients connected to each AP, SSID or VLAN, only with --clients.aggregate-only.",
+			group_labels,
+			nil,
+		),
+
+		omadaClientGroupTrafficDown: prometheus.NewDesc("omada_client_group_traffic_down_bytes",
+			"Total bytes received by the clients connected to each AP, SSID or VLAN, only with --clients.aggregate-only.",
+			group_labels,
+			nil,
+		),
+
+		omadaClientGroupTrafficUp: prometheus.NewDesc("omada_client_group_traffic_up_bytes",
+			"Total bytes sent by the clients connected to each AP, SSID or VLAN, only with --clients.aggregate-only.",
+			group_labels,
+			nil,
+		),
+
+		labels:     client_labels,
+		infoLabels: info_labels,
+		roams:      roams,
+		overflow:   map[string]float64{},
+		history:    map[string]*clientHistory{},
+		client:     c,
 	}
 }
@@ -0,0 +1,179 @@
+package collector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charlie-haley/omada_exporter/pkg/api"
+	"github.com/charlie-haley/omada_exporter/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestAddClientGroups(t *testing.T) {
+	tests := []struct {
+		name    string
+		clients []api.NetworkClient
+		groups  map[clientGroupKey]clientGroup
+	}{
+		{
+			"wired",
+			[]api.NetworkClient{{Mac: "a", TrafficDown: 10, TrafficUp: 1}},
+			map[clientGroupKey]clientGroup{{"vlan", "1"}: {1, 10, 1}},
+		},
+		{
+			"wireless",
+			[]api.NetworkClient{{Mac: "a", Wireless: true, ApName: "ap1", Ssid: "home", TrafficDown: 10, TrafficUp: 1}},
+			map[clientGroupKey]clientGroup{{"vlan", "1"}: {1, 10, 1}, {"ap", "ap1"}: {1, 10, 1}, {"ssid", "home"}: {1, 10, 1}},
+		},
+		{
+			"several clients",
+			[]api.NetworkClient{
+				{Mac: "a", Wireless: true, ApName: "ap1", Ssid: "home", TrafficDown: 10, TrafficUp: 1},
+				{Mac: "b", Wireless: true, ApName: "ap2", Ssid: "home", TrafficDown: 20, TrafficUp: 2},
+				{Mac: "c", TrafficDown: 30, TrafficUp: 3},
+			},
+			map[clientGroupKey]clientGroup{
+				{"vlan", "1"}: {3, 60, 6}, {"ap", "ap1"}: {1, 10, 1}, {"ap", "ap2"}: {1, 20, 2}, {"ssid", "home"}: {2, 30, 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups := map[clientGroupKey]*clientGroup{}
+			for _, item := range tt.clients {
+				addClientGroups(groups, item, "1")
+			}
+			if len(groups) != len(tt.groups) {
+				t.Fatalf("expected %d groups, got %d", len(tt.groups), len(groups))
+			}
+			for key, expected := range tt.groups {
+				if got, ok := groups[key]; !ok || *got != expected {
+					t.Errorf("expected %v for %v, got %v", expected, key, got)
+				}
+			}
+		})
+	}
+}
+
+// clientMacs returns the mac label of each omada_client_traffic_down_bytes series
+func clientMacs(t *testing.T, c *clientCollector) []string {
+	metrics, err := collect(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	macs := []string{}
+	for _, m := range metrics {
+		if !strings.Contains(m.Desc().String(), `"omada_client_traffic_down_bytes"`) {
+			continue
+		}
+		var out dto.Metric
+		m.Write(&out)
+		for _, l := range out.Label {
+			if l.GetName() == "mac" {
+				macs = append(macs, l.GetValue())
+			}
+		}
+	}
+	return macs
+}
+
+func TestClientCollectorMaxSeriesIsStable(t *testing.T) {
+	orders := [][]string{
+		{"CC-00-00-00-00-03", "CC-00-00-00-00-01", "CC-00-00-00-00-04", "CC-00-00-00-00-02"},
+		{"CC-00-00-00-00-02", "CC-00-00-00-00-04", "CC-00-00-00-00-01", "CC-00-00-00-00-03"},
+	}
+
+	for i, order := range orders {
+		t.Run(fmt.Sprintf("order %d", i), func(t *testing.T) {
+			clients := []string{}
+			for _, mac := range order {
+				clients = append(clients, fmt.Sprintf(`{"mac":"%s"}`, mac))
+			}
+			f := newFakeController(t, map[string]string{
+				"/api/v2/sites/s1/clients": `{"errorCode":0,"result":{"data":[` + strings.Join(clients, ",") + `]}}`,
+			})
+			c := NewClientCollector(newTestClient(t, f, config.Config{ClientMaxSeries: 2}), nil)
+
+			macs := clientMacs(t, c)
+			if strings.Join(macs, ",") != "CC-00-00-00-00-01,CC-00-00-00-00-02" {
+				t.Errorf("expected the first 2 clients by mac, got %q", macs)
+			}
+		})
+	}
+}
+
+func TestClientInfoLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels []string
+		info   []string
+	}{
+		{"every label", nil, config.ClientLabels},
+		{"mac and site_id", []string{"mac", "site_id"}, []string{"mac", "site_id"}},
+		{"without site_id", []string{"client", "mac", "site"}, []string{"client", "mac", "site", "site_id"}},
+		{"reordered", []string{"site_id", "ip", "mac"}, []string{"site_id", "ip", "mac"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClientCollector(&api.Client{Config: &config.Config{InfoMetrics: true, ClientLabels: tt.labels}}, nil)
+			expected := fmt.Sprintf("variableLabels: %v", tt.info)
+			if desc := c.omadaClientInfo.String(); !strings.Contains(desc, expected) {
+				t.Errorf("expected %s, got %s", expected, desc)
+			}
+		})
+	}
+}
+
+func TestClientCollectorHistory(t *testing.T) {
+	f := newFakeController(t, map[string]string{
+		"/api/v2/sites/s1/clients": `{"errorCode":0,"result":{"data":[{"mac":"AA"},{"mac":"BB"}]}}`,
+		"/api/v2/sites/s1/setting": `{"errorCode":0,"result":{"site":{"timeZone":"UTC+14:00"}}}`,
+		"/api/v2/sites/s1/insight/pastConnection": `{"errorCode":0,"result":{"totalRows":3,"data":[
+			{"mac":"BB","firstSeen":1000},{"mac":"CC","firstSeen":2000},{"mac":"CC","firstSeen":3000}
+		]}}`,
+	})
+	c := NewClientCollector(newTestClient(t, f, config.Config{ClientHistory: true}), nil)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(NewOmadaCollector(map[string]Collector{"client": c}))
+
+	expected := `
+# HELP omada_client_sessions_ended_today Number of client connections to the site that ended since midnight in the site's time zone, only with --clients.history.
+# TYPE omada_client_sessions_ended_today gauge
+omada_client_sessions_ended_today{site="Default",site_id="s1"} 3
+# HELP omada_client_sessions_skipped_today Number of client connections to the site that ended since midnight but weren't fetched because too many ended between scrapes, only with --clients.history.
+# TYPE omada_client_sessions_skipped_today gauge
+omada_client_sessions_skipped_today{site="Default",site_id="s1"} 0
+# HELP omada_clients_distinct_today Number of distinct clients connected to the site since midnight in the site's time zone, only with --clients.history.
+# TYPE omada_clients_distinct_today gauge
+omada_clients_distinct_today{site="Default",site_id="s1"} 3
+`
+	var lastEnd string
+	for scrape := 0; scrape < 2; scrape++ {
+		err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "omada_clients_distinct_today", "omada_client_sessions_ended_today", "omada_client_sessions_skipped_today")
+		if err != nil {
+			t.Fatalf("scrape %d: %s", scrape, err)
+		}
+
+		q := f.query("/api/v2/sites/s1/insight/pastConnection")
+		if scrape == 0 {
+			now := time.Now().In(time.FixedZone("", 14*3600))
+			midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+			if start := q.Get("filters.timeStart"); start != strconv.FormatInt(midnight.UnixMilli(), 10) {
+				t.Errorf("expected the first fetch to start at midnight in the site's time zone %d, got %s", midnight.UnixMilli(), start)
+			}
+		} else if start := q.Get("filters.timeStart"); start != lastEnd {
+			t.Errorf("expected the second fetch to start at the end of the first %s, got %s", lastEnd, start)
+		}
+		lastEnd = q.Get("filters.timeEnd")
+	}
+	if n := f.count("/api/v2/sites/s1/setting"); n != 1 {
+		t.Errorf("expected the time zone to be fetched once, got %d", n)
+	}
+}
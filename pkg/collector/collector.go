@@ -0,0 +1,111 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/charlie-haley/omada_exporter/pkg/api"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/rs/zerolog/log"
+)
+
+// Collector is implemented by each of the omada collectors. Update returns an
+// error if the collector failed to fetch its metrics from the controller.
+type Collector interface {
+	Describe(ch chan<- *prometheus.Desc)
+	Update(ch chan<- prometheus.Metric) error
+}
+
+// OmadaCollector runs the enabled collectors and reports whether each of them succeeded and how long they took.
+type OmadaCollector struct {
+	omadaUp                     *prometheus.Desc
+	omadaScrapeCollectorSuccess *prometheus.Desc
+	omadaScrapeCollectorSeconds *prometheus.Desc
+	collectors                  map[string]Collector
+	unreachable                 bool
+}
+
+func (c *OmadaCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.omadaUp
+	ch <- c.omadaScrapeCollectorSuccess
+	ch <- c.omadaScrapeCollectorSeconds
+	for _, collector := range c.collectors {
+		collector.Describe(ch)
+	}
+}
+
+func (c *OmadaCollector) Collect(ch chan<- prometheus.Metric) {
+	if c.unreachable {
+		ch <- prometheus.MustNewConstMetric(c.omadaUp, prometheus.GaugeValue, 0)
+		return
+	}
+
+	var mu sync.Mutex
+	up := float64(1)
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(c.collectors))
+	for name, collector := range c.collectors {
+		go func(name string, collector Collector) {
+			defer wg.Done()
+			if !c.execute(name, collector, ch) {
+				mu.Lock()
+				up = 0
+				mu.Unlock()
+			}
+		}(name, collector)
+	}
+	wg.Wait()
+
+	ch <- prometheus.MustNewConstMetric(c.omadaUp, prometheus.GaugeValue, up)
+}
+
+func (c *OmadaCollector) execute(name string, collector Collector, ch chan<- prometheus.Metric) bool {
+	begin := time.Now()
+	err := collector.Update(ch)
+	duration := time.Since(begin)
+
+	success := float64(1)
+	if err != nil {
+		log.Error().Err(err).Str("collector", name).Msg("Collector failed")
+		success = 0
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.omadaScrapeCollectorSuccess, prometheus.GaugeValue, success, name)
+	ch <- prometheus.MustNewConstMetric(c.omadaScrapeCollectorSeconds, prometheus.GaugeValue, duration.Seconds(), name)
+	return err == nil
+}
+
+// infoMetrics returns whether the collectors use the info metric label scheme, where the value metrics only have
+// the labels identifying what they measure and the other labels are moved to an omada_*_info metric
+func infoMetrics(client *api.Client) bool {
+	return client != nil && client.Config.InfoMetrics
+}
+
+// NewUnreachableCollector returns a collector which only reports omada_up as 0, for a controller that couldn't be logged in to
+func NewUnreachableCollector() *OmadaCollector {
+	c := NewOmadaCollector(nil)
+	c.unreachable = true
+	return c
+}
+
+func NewOmadaCollector(collectors map[string]Collector) *OmadaCollector {
+	return &OmadaCollector{
+		omadaUp: prometheus.NewDesc("omada_up",
+			"Whether the last scrape of the Omada controller was successful, 0 if any collector failed.",
+			nil,
+			nil,
+		),
+		omadaScrapeCollectorSuccess: prometheus.NewDesc("omada_scrape_collector_success",
+			"Whether a collector succeeded.",
+			[]string{"collector"},
+			nil,
+		),
+		omadaScrapeCollectorSeconds: prometheus.NewDesc("omada_scrape_collector_duration_seconds",
+			"Duration of a collector scrape.",
+			[]string{"collector"},
+			nil,
+		),
+		collectors: collectors,
+	}
+}
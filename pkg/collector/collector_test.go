@@ -0,0 +1,48 @@
+package collector
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/charlie-haley/omada_exporter/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestOmadaCollectorConcurrentCollect(t *testing.T) {
+	f := newFakeController(t, portRoutes(2))
+	client := newTestClient(t, f, config.Config{})
+	c := NewOmadaCollector(map[string]Collector{
+		"client": NewClientCollector(client, nil),
+		"device": NewDeviceCollector(client),
+		"port":   NewPortCollector(client),
+	})
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			registry := prometheus.NewRegistry()
+			registry.MustRegister(c)
+			_, err := registry.Gather()
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestUnreachableCollector(t *testing.T) {
+	expected := `
+# HELP omada_up Whether the last scrape of the Omada controller was successful, 0 if any collector failed.
+# TYPE omada_up gauge
+omada_up 0
+`
+	err := testutil.CollectAndCompare(NewUnreachableCollector(), strings.NewReader(expected))
+	if err != nil {
+		t.Error(err)
+	}
+}
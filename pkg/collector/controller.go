@@ -1,12 +1,14 @@
 package collector
 
 import (
+	"fmt"
+
 	"github.com/charlie-haley/omada_exporter/pkg/api"
 	"github.com/prometheus/client_golang/prometheus"
-	log "github.com/rs/zerolog/log"
 )
 
 type controllerCollector struct {
+	omadaControllerInfo                  *prometheus.Desc
 	omadaControllerUptimeSeconds         *prometheus.Desc
 	omadaControllerStorageUsedBytes      *prometheus.Desc
 	omadaControllerStorageAvailableBytes *prometheus.Desc
@@ -14,50 +16,65 @@ type controllerCollector struct {
 }
 
 func (c *controllerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.omadaControllerInfo
 	ch <- c.omadaControllerUptimeSeconds
 	ch <- c.omadaControllerStorageUsedBytes
 	ch <- c.omadaControllerStorageAvailableBytes
 }
 
-func (c *controllerCollector) Collect(ch chan<- prometheus.Metric) {
+func (c *controllerCollector) Update(ch chan<- prometheus.Metric) error {
 	client := c.client
-	config := c.client.Config
 
-	site := config.Site
 	controller, err := client.GetController()
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to get controller")
-		return
+		return fmt.Errorf("failed to get controller: %s", err)
 	}
 
-	ch <- prometheus.MustNewConstMetric(c.omadaControllerUptimeSeconds, prometheus.GaugeValue, controller.Uptime/1000,
-		controller.Name, controller.Model, controller.ControllerVersion, controller.ControllerVersion, controller.MacAddress, site, client.SiteId)
+	for _, site := range client.Sites {
+		labels := []string{controller.Name, controller.Model, controller.ControllerVersion, controller.FirmwareVersion, controller.MacAddress, site.Name, site.Id}
+		if infoMetrics(client) {
+			ch <- prometheus.MustNewConstMetric(c.omadaControllerInfo, prometheus.GaugeValue, 1, labels...)
+			labels = []string{controller.MacAddress, site.Id}
+		}
 
-	for _, s := range controller.Storage {
-		ch <- prometheus.MustNewConstMetric(c.omadaControllerStorageUsedBytes, prometheus.GaugeValue, s.Used*1000000000,
-			s.Name, controller.Name, controller.Model, controller.ControllerVersion, controller.ControllerVersion, controller.MacAddress, site, client.SiteId)
+		ch <- prometheus.MustNewConstMetric(c.omadaControllerUptimeSeconds, prometheus.GaugeValue, controller.Uptime/1000, labels...)
 
-		ch <- prometheus.MustNewConstMetric(c.omadaControllerStorageAvailableBytes, prometheus.GaugeValue, s.Total*100000000,
-			s.Name, controller.Name, controller.Model, controller.ControllerVersion, controller.ControllerVersion, controller.MacAddress, site, client.SiteId)
+		for _, s := range controller.Storage {
+			storageLabels := append([]string{s.Name}, labels...)
+			ch <- prometheus.MustNewConstMetric(c.omadaControllerStorageUsedBytes, prometheus.GaugeValue, s.Used*1000000000, storageLabels...)
+			ch <- prometheus.MustNewConstMetric(c.omadaControllerStorageAvailableBytes, prometheus.GaugeValue, s.Total*100000000, storageLabels...)
+		}
 	}
 
+	return nil
 }
 
 func NewControllerCollector(c *api.Client) *controllerCollector {
+	infoLabels := []string{"controller_name", "model", "controller_version", "firmware_version", "mac", "site", "site_id"}
+	labels := infoLabels
+	if infoMetrics(c) {
+		labels = []string{"mac", "site_id"}
+	}
+
 	return &controllerCollector{
+		omadaControllerInfo: prometheus.NewDesc("omada_controller_info",
+			"Metadata of the controller, always 1. Only with --info-metrics, which removes these labels from the other controller metrics.",
+			infoLabels,
+			nil,
+		),
 		omadaControllerUptimeSeconds: prometheus.NewDesc("omada_controller_uptime_seconds",
 			"Uptime of the controller.",
-			[]string{"controller_name", "model", "controller_version", "firmware_version", "mac", "site", "site_id"},
+			labels,
 			nil,
 		),
 		omadaControllerStorageUsedBytes: prometheus.NewDesc("omada_controller_storage_used_bytes",
 			"Storage used on the controller.",
-			[]string{"storage_name", "controller_name", "model", "controller_version", "firmware_version", "mac", "site", "site_id"},
+			append([]string{"storage_name"}, labels...),
 			nil,
 		),
 		omadaControllerStorageAvailableBytes: prometheus.NewDesc("omada_controller_storage_available_bytes",
 			"Total storage available for the controller.",
-			[]string{"storage_name", "controller_name", "model", "controller_version", "firmware_version", "mac", "site", "site_id"},
+			append([]string{"storage_name"}, labels...),
 			nil,
 		),
 		client: c,
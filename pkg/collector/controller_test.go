@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charlie-haley/omada_exporter/pkg/config"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestControllerCollectorVersions(t *testing.T) {
+	tests := []struct {
+		name        string
+		infoMetrics bool
+		metric      string
+	}{
+		{"labels", false, "omada_controller_uptime_seconds"},
+		{"info metric", true, "omada_controller_info"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeController(t, map[string]string{
+				"/api/v2/maintenance/controllerStatus": `{"errorCode":0,"result":{"name":"oc200","macAddress":"AA-AA-AA-AA-AA-AA","model":"OC200","firmwareVersion":"1.2.3","controllerVersion":"5.9.31","upTime":1000}}`,
+			})
+			c := NewControllerCollector(newTestClient(t, f, config.Config{InfoMetrics: tt.infoMetrics}))
+
+			metrics, err := collect(c)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			labels := map[string]string{}
+			for _, m := range metrics {
+				if !strings.Contains(m.Desc().String(), `"`+tt.metric+`"`) {
+					continue
+				}
+				var out dto.Metric
+				m.Write(&out)
+				for _, l := range out.Label {
+					labels[l.GetName()] = l.GetValue()
+				}
+			}
+			if labels["controller_version"] != "5.9.31" {
+				t.Errorf("expected controller_version 5.9.31, got %q", labels["controller_version"])
+			}
+			if labels["firmware_version"] != "1.2.3" {
+				t.Errorf("expected firmware_version 1.2.3, got %q", labels["firmware_version"])
+			}
+		})
+	}
+}
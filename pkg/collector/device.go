@@ -1,80 +1,273 @@
 package collector
 
 import (
+	"fmt"
+	"sync"
+	"time"
+
 	"github.com/charlie-haley/omada_exporter/pkg/api"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/rs/zerolog/log"
 )
 
 type deviceCollector struct {
+	omadaDeviceInfo           *prometheus.Desc
 	omadaDeviceUptimeSeconds  *prometheus.Desc
+	omadaDeviceStatus         *prometheus.Desc
+	omadaDeviceLastSeen       *prometheus.Desc
 	omadaDeviceCpuPercentage  *prometheus.Desc
 	omadaDeviceMemPercentage  *prometheus.Desc
 	omadaDeviceNeedUpgrade    *prometheus.Desc
+	omadaDeviceFirmwareInfo   *prometheus.Desc
+	omadaDevicePendingUpgrade *prometheus.Desc
 	omadaDeviceTxRate         *prometheus.Desc
 	omadaDeviceRxRate         *prometheus.Desc
 	omadaDevicePoeRemainWatts *prometheus.Desc
+	omadaDevicePoeBudgetWatts *prometheus.Desc
+	omadaDevicePoeUsedWatts   *prometheus.Desc
+	omadaDevicePoeUsedPct     *prometheus.Desc
+	omadaDeviceMeshRepeater   *prometheus.Desc
+	omadaDeviceMeshHops       *prometheus.Desc
+	omadaDeviceMeshRssiDbm    *prometheus.Desc
+	omadaDeviceMeshSnrDbm     *prometheus.Desc
+	omadaDeviceMeshTxRate     *prometheus.Desc
+	omadaDeviceMeshRxRate     *prometheus.Desc
+	omadaDeviceTemperature    *prometheus.Desc
+	omadaDeviceFanStatus      *prometheus.Desc
+	omadaDeviceFanSpeedRpm    *prometheus.Desc
+	omadaDevicePsuStatus      *prometheus.Desc
 	omadaDeviceDownload       *prometheus.Desc
 	omadaDeviceUpload         *prometheus.Desc
+	firmwareMu                sync.Mutex
+	firmware                  map[firmwareKey]firmwareEntry
 	client                    *api.Client
 }
 
+// the latest firmware of a model is only fetched once every firmwareTTL, rather than for every device on every scrape
+const firmwareTTL = time.Hour
+
+// firmwareKey is the devices that share the same latest firmware
+type firmwareKey struct {
+	model     string
+	hwVersion string
+	version   string
+}
+
+type firmwareEntry struct {
+	firmware *api.Firmware
+	fetched  time.Time
+}
+
+// latestFirmware returns the latest firmware for a device, from the cache if a device of the same model and version fetched it recently
+func (c *deviceCollector) latestFirmware(siteId string, device api.Device, now time.Time) (*api.Firmware, error) {
+	key := firmwareKey{device.Model, device.HwVersion, device.Version}
+	c.firmwareMu.Lock()
+	entry, ok := c.firmware[key]
+	c.firmwareMu.Unlock()
+	if ok && now.Sub(entry.fetched) < firmwareTTL {
+		return entry.firmware, nil
+	}
+
+	firmware, err := c.client.GetLatestFirmware(siteId, device.Mac)
+	if err != nil {
+		return nil, err
+	}
+
+	c.firmwareMu.Lock()
+	defer c.firmwareMu.Unlock()
+	for k, e := range c.firmware {
+		if now.Sub(e.fetched) >= firmwareTTL {
+			delete(c.firmware, k)
+		}
+	}
+	c.firmware[key] = firmwareEntry{firmware: firmware, fetched: now}
+	return firmware, nil
+}
+
 func (c *deviceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.omadaDeviceInfo
 	ch <- c.omadaDeviceUptimeSeconds
-	ch <- c.omadaDeviceUptimeSeconds
+	ch <- c.omadaDeviceStatus
+	ch <- c.omadaDeviceLastSeen
 	ch <- c.omadaDeviceCpuPercentage
 	ch <- c.omadaDeviceMemPercentage
 	ch <- c.omadaDeviceNeedUpgrade
+	ch <- c.omadaDeviceFirmwareInfo
+	ch <- c.omadaDevicePendingUpgrade
 	ch <- c.omadaDeviceTxRate
 	ch <- c.omadaDeviceRxRate
 	ch <- c.omadaDevicePoeRemainWatts
+	ch <- c.omadaDevicePoeBudgetWatts
+	ch <- c.omadaDevicePoeUsedWatts
+	ch <- c.omadaDevicePoeUsedPct
+	ch <- c.omadaDeviceMeshRepeater
+	ch <- c.omadaDeviceMeshHops
+	ch <- c.omadaDeviceMeshRssiDbm
+	ch <- c.omadaDeviceMeshSnrDbm
+	ch <- c.omadaDeviceMeshTxRate
+	ch <- c.omadaDeviceMeshRxRate
+	ch <- c.omadaDeviceTemperature
+	ch <- c.omadaDeviceFanStatus
+	ch <- c.omadaDeviceFanSpeedRpm
+	ch <- c.omadaDevicePsuStatus
 	ch <- c.omadaDeviceDownload
 	ch <- c.omadaDeviceUpload
 }
 
-func (c *deviceCollector) Collect(ch chan<- prometheus.Metric) {
+func (c *deviceCollector) Update(ch chan<- prometheus.Metric) error {
 	client := c.client
-	config := c.client.Config
 
-	site := config.Site
-	devices, err := client.GetDevices()
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to get devices")
-		return
-	}
+	var failed error
+	for _, site := range client.Sites {
+		devices, err := client.GetDevices(site.Id)
+		if err != nil {
+			failed = fmt.Errorf("failed to get devices for site %s: %s", site.Name, err)
+			continue
+		}
+
+		pendingUpgrade := map[string]float64{}
 
-	for _, item := range devices {
-		needUpgrade := float64(0)
-		if item.NeedUpgrade {
-			needUpgrade = 1
+		// access points other access points are connected to over mesh are the roots of the mesh
+		meshRoots := map[string]bool{}
+		for _, item := range devices {
+			if item.WirelessLinked {
+				meshRoots[item.UplinkDeviceMac] = true
+			}
 		}
-		labels := []string{item.Name, item.Model, item.Version, item.Ip, item.Mac, site, client.SiteId, item.Type}
-
-		ch <- prometheus.MustNewConstMetric(c.omadaDeviceUptimeSeconds, prometheus.GaugeValue, item.Uptime, labels...)
-		ch <- prometheus.MustNewConstMetric(c.omadaDeviceCpuPercentage, prometheus.GaugeValue, item.CpuUtil, labels...)
-		ch <- prometheus.MustNewConstMetric(c.omadaDeviceMemPercentage, prometheus.GaugeValue, item.MemUtil, labels...)
-		ch <- prometheus.MustNewConstMetric(c.omadaDeviceNeedUpgrade, prometheus.GaugeValue, needUpgrade, labels...)
-		ch <- prometheus.MustNewConstMetric(c.omadaDeviceDownload, prometheus.CounterValue, float64(item.Download), labels...)
-		ch <- prometheus.MustNewConstMetric(c.omadaDeviceUpload, prometheus.CounterValue, float64(item.Upload), labels...)
-		if item.Type == "ap" {
-			ch <- prometheus.MustNewConstMetric(c.omadaDeviceTxRate, prometheus.GaugeValue, item.TxRate, labels...)
-			ch <- prometheus.MustNewConstMetric(c.omadaDeviceRxRate, prometheus.GaugeValue, item.RxRate, labels...)
+
+		for _, item := range devices {
+			needUpgrade := float64(0)
+			if item.NeedUpgrade {
+				needUpgrade = 1
+			}
+			labels := []string{item.Name, item.Model, item.Version, item.Ip, item.Mac, site.Name, site.Id, item.Type}
+			if infoMetrics(client) {
+				ch <- prometheus.MustNewConstMetric(c.omadaDeviceInfo, prometheus.GaugeValue, 1,
+					item.Name, item.Model, item.Version, item.HwVersion, item.Ip, item.Mac, site.Name, site.Id, item.Type)
+				labels = []string{item.Mac, site.Id}
+			}
+
+			ch <- prometheus.MustNewConstMetric(c.omadaDeviceUptimeSeconds, prometheus.GaugeValue, item.Uptime, labels...)
+			status := item.Status()
+			for _, s := range api.DeviceStatuses {
+				ch <- prometheus.MustNewConstMetric(c.omadaDeviceStatus, prometheus.GaugeValue, boolToFloat(s == status), append(labels, s)...)
+			}
+			if item.LastSeen > 0 {
+				ch <- prometheus.MustNewConstMetric(c.omadaDeviceLastSeen, prometheus.GaugeValue, item.LastSeen/1000, labels...)
+			}
+			ch <- prometheus.MustNewConstMetric(c.omadaDeviceCpuPercentage, prometheus.GaugeValue, item.CpuUtil, labels...)
+			ch <- prometheus.MustNewConstMetric(c.omadaDeviceMemPercentage, prometheus.GaugeValue, item.MemUtil, labels...)
+			ch <- prometheus.MustNewConstMetric(c.omadaDeviceNeedUpgrade, prometheus.GaugeValue, needUpgrade, labels...)
+
+			// only devices with an upgrade available have their latest firmware fetched, like the device detail
+			// a failure only leaves it out of the firmware info
+			latest, released := item.Version, ""
+			if item.NeedUpgrade {
+				firmware, err := c.latestFirmware(site.Id, item, time.Now())
+				if err != nil {
+					log.Warn().Err(err).Str("device", item.Mac).Str("site", site.Name).Msg("Failed to get latest firmware")
+				} else {
+					latest, released = firmware.Version, firmware.Released()
+				}
+			}
+			ch <- prometheus.MustNewConstMetric(c.omadaDeviceFirmwareInfo, prometheus.GaugeValue, 1,
+				item.Name, item.Mac, item.Model, item.Type, item.Version, item.HwVersion, latest, released, site.Name, site.Id)
+			pendingUpgrade[item.Model] += needUpgrade
+
+			ch <- prometheus.MustNewConstMetric(c.omadaDeviceDownload, prometheus.CounterValue, float64(item.Download), labels...)
+			ch <- prometheus.MustNewConstMetric(c.omadaDeviceUpload, prometheus.CounterValue, float64(item.Upload), labels...)
+			if item.Type == "ap" {
+				ch <- prometheus.MustNewConstMetric(c.omadaDeviceTxRate, prometheus.GaugeValue, item.TxRate, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaDeviceRxRate, prometheus.GaugeValue, item.RxRate, labels...)
+			}
+			if item.Type == "switch" {
+				ch <- prometheus.MustNewConstMetric(c.omadaDevicePoeRemainWatts, prometheus.GaugeValue, item.PoeRemain, labels...)
+				// switches without PoE don't report a budget
+				if item.PoeBudget > 0 {
+					used := item.PoeBudget - item.PoeRemain
+					ch <- prometheus.MustNewConstMetric(c.omadaDevicePoeBudgetWatts, prometheus.GaugeValue, item.PoeBudget, labels...)
+					ch <- prometheus.MustNewConstMetric(c.omadaDevicePoeUsedWatts, prometheus.GaugeValue, used, labels...)
+					ch <- prometheus.MustNewConstMetric(c.omadaDevicePoeUsedPct, prometheus.GaugeValue, used/item.PoeBudget*100, labels...)
+				}
+			}
+			if item.Type == "ap" && item.WirelessLinked {
+				meshLabels := append(labels, item.UplinkDeviceName, item.UplinkDeviceMac)
+				ch <- prometheus.MustNewConstMetric(c.omadaDeviceMeshRepeater, prometheus.GaugeValue, 1, meshLabels...)
+				// the hops are unknown when the reported uplinks loop
+				if hops, ok := api.MeshHops(item, devices); ok {
+					ch <- prometheus.MustNewConstMetric(c.omadaDeviceMeshHops, prometheus.GaugeValue, float64(hops), meshLabels...)
+				} else {
+					log.Warn().Str("device", item.Mac).Str("site", site.Name).Msg("Mesh uplinks of the device form a loop")
+				}
+				if uplink := item.WirelessUplink; uplink != nil {
+					ch <- prometheus.MustNewConstMetric(c.omadaDeviceMeshRssiDbm, prometheus.GaugeValue, uplink.Rssi, meshLabels...)
+					ch <- prometheus.MustNewConstMetric(c.omadaDeviceMeshSnrDbm, prometheus.GaugeValue, uplink.Snr, meshLabels...)
+					ch <- prometheus.MustNewConstMetric(c.omadaDeviceMeshTxRate, prometheus.GaugeValue, uplink.TxRate, meshLabels...)
+					ch <- prometheus.MustNewConstMetric(c.omadaDeviceMeshRxRate, prometheus.GaugeValue, uplink.RxRate, meshLabels...)
+				}
+			} else if item.Type == "ap" && meshRoots[item.Mac] {
+				ch <- prometheus.MustNewConstMetric(c.omadaDeviceMeshRepeater, prometheus.GaugeValue, 0, append(labels, "", "")...)
+				ch <- prometheus.MustNewConstMetric(c.omadaDeviceMeshHops, prometheus.GaugeValue, 0, append(labels, "", "")...)
+			}
+
+			// an extra request per switch and gateway on every scrape, which only the hardware health metrics need,
+			// so a device whose detail can't be fetched only misses those rather than failing the collector
+			if item.Type == "switch" || item.Type == "gateway" {
+				detail, err := client.GetDeviceDetail(site.Id, item)
+				if err != nil {
+					log.Warn().Err(err).Str("device", item.Mac).Str("site", site.Name).Msg("Failed to get device detail")
+					continue
+				}
+				// models without the sensors don't report them, so there's nothing to emit
+				for _, t := range detail.Temperatures {
+					ch <- prometheus.MustNewConstMetric(c.omadaDeviceTemperature, prometheus.GaugeValue, t.Temperature, append(labels, t.Name)...)
+				}
+				for _, f := range detail.Fans {
+					ch <- prometheus.MustNewConstMetric(c.omadaDeviceFanStatus, prometheus.GaugeValue, boolToFloat(api.HealthyStatus(f.Status)), append(labels, f.Name)...)
+					ch <- prometheus.MustNewConstMetric(c.omadaDeviceFanSpeedRpm, prometheus.GaugeValue, f.Speed, append(labels, f.Name)...)
+				}
+				for _, p := range detail.PowerSupplies {
+					ch <- prometheus.MustNewConstMetric(c.omadaDevicePsuStatus, prometheus.GaugeValue, boolToFloat(api.HealthyStatus(p.Status)), append(labels, p.Name)...)
+				}
+			}
 		}
-		if item.Type == "switch" {
-			ch <- prometheus.MustNewConstMetric(c.omadaDevicePoeRemainWatts, prometheus.GaugeValue, item.PoeRemain, labels...)
+
+		for model, v := range pendingUpgrade {
+			ch <- prometheus.MustNewConstMetric(c.omadaDevicePendingUpgrade, prometheus.GaugeValue, v, model, site.Name, site.Id)
 		}
 	}
+
+	return failed
 }
 
 func NewDeviceCollector(c *api.Client) *deviceCollector {
 	labels := []string{"device", "model", "version", "ip", "mac", "site", "site_id", "device_type"}
+	if infoMetrics(c) {
+		labels = []string{"mac", "site_id"}
+	}
+	meshLabels := append(labels, "uplink_device", "uplink_mac")
 
 	return &deviceCollector{
+		omadaDeviceInfo: prometheus.NewDesc("omada_device_info",
+			"Metadata of the device, always 1. Only with --info-metrics, which removes these labels from the other device metrics.",
+			[]string{"device", "model", "version", "hardware_version", "ip", "mac", "site", "site_id", "device_type"},
+			nil,
+		),
 		omadaDeviceUptimeSeconds: prometheus.NewDesc("omada_device_uptime_seconds",
 			"Uptime of the device.",
 			labels,
 			nil,
 		),
+		omadaDeviceStatus: prometheus.NewDesc("omada_device_status",
+			"The status of the device, 1 for the current status of connected, disconnected, pending, isolated, upgrading, provisioning or heartbeat_missed.",
+			append(labels, "status"),
+			nil,
+		),
+		omadaDeviceLastSeen: prometheus.NewDesc("omada_device_last_seen_timestamp_seconds",
+			"The time the controller last heard from the device, as a unix timestamp.",
+			labels,
+			nil,
+		),
 		omadaDeviceCpuPercentage: prometheus.NewDesc("omada_device_cpu_percentage",
 			"Percentage of device CPU used.",
 			labels,
@@ -90,6 +283,16 @@ func NewDeviceCollector(c *api.Client) *deviceCollector {
 			labels,
 			nil,
 		),
+		omadaDeviceFirmwareInfo: prometheus.NewDesc("omada_device_firmware_info",
+			"The current and latest available firmware of the device, always 1.",
+			[]string{"device", "mac", "model", "device_type", "firmware_version", "hardware_version", "latest_firmware_version", "latest_firmware_release_date", "site", "site_id"},
+			nil,
+		),
+		omadaDevicePendingUpgrade: prometheus.NewDesc("omada_device_pending_upgrade",
+			"Number of devices of the site with a firmware upgrade available, by model.",
+			[]string{"model", "site", "site_id"},
+			nil,
+		),
 		omadaDeviceDownload: prometheus.NewDesc("omada_device_download",
 			"Device download traffic.",
 			labels,
@@ -115,6 +318,72 @@ func NewDeviceCollector(c *api.Client) *deviceCollector {
 			labels,
 			nil,
 		),
-		client: c,
+		omadaDevicePoeBudgetWatts: prometheus.NewDesc("omada_device_poe_budget_watts",
+			"The total PoE power budget of the device in watts.",
+			labels,
+			nil,
+		),
+		omadaDevicePoeUsedWatts: prometheus.NewDesc("omada_device_poe_used_watts",
+			"The PoE power consumed from the device's budget in watts.",
+			labels,
+			nil,
+		),
+		omadaDevicePoeUsedPct: prometheus.NewDesc("omada_device_poe_used_pct",
+			"The percentage of the device's PoE power budget consumed.",
+			labels,
+			nil,
+		),
+		omadaDeviceMeshRepeater: prometheus.NewDesc("omada_device_mesh_repeater",
+			"1 for an access point connected to its uplink over mesh, 0 for the wired root of a mesh.",
+			meshLabels,
+			nil,
+		),
+		omadaDeviceMeshHops: prometheus.NewDesc("omada_device_mesh_hops",
+			"Number of mesh links between the access point and the wired root of the mesh, not reported when the mesh uplinks form a loop.",
+			meshLabels,
+			nil,
+		),
+		omadaDeviceMeshRssiDbm: prometheus.NewDesc("omada_device_mesh_uplink_rssi_dbm",
+			"The RSSI of the access point's mesh uplink in dBm.",
+			meshLabels,
+			nil,
+		),
+		omadaDeviceMeshSnrDbm: prometheus.NewDesc("omada_device_mesh_uplink_snr_dbm",
+			"The signal to noise ratio of the access point's mesh uplink in dBm.",
+			meshLabels,
+			nil,
+		),
+		omadaDeviceMeshTxRate: prometheus.NewDesc("omada_device_mesh_uplink_tx_rate",
+			"The tx rate of the access point's mesh uplink.",
+			meshLabels,
+			nil,
+		),
+		omadaDeviceMeshRxRate: prometheus.NewDesc("omada_device_mesh_uplink_rx_rate",
+			"The rx rate of the access point's mesh uplink.",
+			meshLabels,
+			nil,
+		),
+		omadaDeviceTemperature: prometheus.NewDesc("omada_device_temperature_celsius",
+			"The temperature of a sensor in the device in celsius.",
+			append(labels, "sensor"),
+			nil,
+		),
+		omadaDeviceFanStatus: prometheus.NewDesc("omada_device_fan_status",
+			"1 if a fan in the device is working normally, 0 if it has a fault.",
+			append(labels, "fan"),
+			nil,
+		),
+		omadaDeviceFanSpeedRpm: prometheus.NewDesc("omada_device_fan_speed_rpm",
+			"The speed of a fan in the device in rpm.",
+			append(labels, "fan"),
+			nil,
+		),
+		omadaDevicePsuStatus: prometheus.NewDesc("omada_device_psu_status",
+			"1 if a power supply of the device is working normally, 0 if it has failed or has no power.",
+			append(labels, "psu"),
+			nil,
+		),
+		firmware: map[firmwareKey]firmwareEntry{},
+		client:   c,
 	}
 }
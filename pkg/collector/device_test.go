@@ -0,0 +1,100 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charlie-haley/omada_exporter/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDeviceCollectorSkipsMissingDetail(t *testing.T) {
+	f := newFakeController(t, map[string]string{
+		"/api/v2/sites/s1/devices": `{"errorCode":0,"result":[
+			{"name":"gw1","type":"gateway","mac":"AA-AA-AA-AA-AA-01","status":14},
+			{"name":"gw2","type":"gateway","mac":"AA-AA-AA-AA-AA-02","status":14}
+		]}`,
+		"/api/v2/sites/s1/gateways/AA-AA-AA-AA-AA-01": `{"errorCode":0,"result":{"temperatures":[{"name":"cpu","temperature":45}]}}`,
+	})
+	c := NewDeviceCollector(newTestClient(t, f, config.Config{}))
+
+	metrics, err := collect(c)
+	if err != nil {
+		t.Fatalf("expected a missing device detail not to fail the collector, got %s", err)
+	}
+	if n := countMetrics(metrics, "omada_device_temperature_celsius"); n != 1 {
+		t.Errorf("expected the temperature of the device with a detail, got %d", n)
+	}
+	if n := countMetrics(metrics, "omada_device_uptime_seconds"); n != 2 {
+		t.Errorf("expected the other metrics of both devices, got %d", n)
+	}
+}
+
+func TestDevicePendingUpgrade(t *testing.T) {
+	f := newFakeController(t, map[string]string{
+		"/api/v2/sites/s1/devices": `{"errorCode":0,"result":[
+			{"name":"ap1","type":"ap","model":"EAP225","mac":"AA-AA-AA-AA-AA-01","needUpgrade":true},
+			{"name":"ap2","type":"ap","model":"EAP225","mac":"AA-AA-AA-AA-AA-02","needUpgrade":true},
+			{"name":"ap3","type":"ap","model":"EAP245","mac":"AA-AA-AA-AA-AA-03"}
+		]}`,
+		"/api/v2/sites/s1/devices/AA-AA-AA-AA-AA-01/latest-firmware-info": `{"errorCode":0,"result":{"lastFwVer":"5.1"}}`,
+		"/api/v2/sites/s1/devices/AA-AA-AA-AA-AA-02/latest-firmware-info": `{"errorCode":0,"result":{"lastFwVer":"5.1"}}`,
+	})
+	c := NewDeviceCollector(newTestClient(t, f, config.Config{}))
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(NewOmadaCollector(map[string]Collector{"device": c}))
+
+	expected := `
+# HELP omada_device_pending_upgrade Number of devices of the site with a firmware upgrade available, by model.
+# TYPE omada_device_pending_upgrade gauge
+omada_device_pending_upgrade{model="EAP225",site="Default",site_id="s1"} 2
+omada_device_pending_upgrade{model="EAP245",site="Default",site_id="s1"} 0
+`
+	err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "omada_device_pending_upgrade")
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeviceLatestFirmwareCache(t *testing.T) {
+	f := newFakeController(t, map[string]string{
+		"/api/v2/sites/s1/devices": `{"errorCode":0,"result":[
+			{"name":"ap1","type":"ap","model":"EAP225","version":"5.0","mac":"AA-AA-AA-AA-AA-01","needUpgrade":true},
+			{"name":"ap2","type":"ap","model":"EAP225","version":"5.0","mac":"AA-AA-AA-AA-AA-02","needUpgrade":true},
+			{"name":"ap3","type":"ap","model":"EAP225","version":"4.9","mac":"AA-AA-AA-AA-AA-03","needUpgrade":true},
+			{"name":"ap4","type":"ap","model":"EAP245","version":"5.0","mac":"AA-AA-AA-AA-AA-04","needUpgrade":true}
+		]}`,
+		"/api/v2/sites/s1/devices/AA-AA-AA-AA-AA-01/latest-firmware-info": `{"errorCode":0,"result":{"lastFwVer":"5.1"}}`,
+		"/api/v2/sites/s1/devices/AA-AA-AA-AA-AA-02/latest-firmware-info": `{"errorCode":0,"result":{"lastFwVer":"5.1"}}`,
+		"/api/v2/sites/s1/devices/AA-AA-AA-AA-AA-03/latest-firmware-info": `{"errorCode":0,"result":{"lastFwVer":"5.1"}}`,
+	})
+	c := NewDeviceCollector(newTestClient(t, f, config.Config{}))
+	f.reset()
+
+	for scrape := 0; scrape < 3; scrape++ {
+		metrics, err := collect(c)
+		if err != nil {
+			t.Fatalf("expected a failed firmware lookup not to fail the collector, got %s", err)
+		}
+		if n := countMetrics(metrics, "omada_device_firmware_info"); n != 4 {
+			t.Errorf("expected the firmware info of every device, got %d", n)
+		}
+	}
+
+	tests := []struct {
+		mac      string
+		requests int
+	}{
+		{"AA-AA-AA-AA-AA-01", 1},
+		{"AA-AA-AA-AA-AA-02", 0},
+		{"AA-AA-AA-AA-AA-03", 1},
+		// failures aren't cached, so they're retried on the next scrape
+		{"AA-AA-AA-AA-AA-04", 3},
+	}
+	for _, tt := range tests {
+		if n := f.count("/api/v2/sites/s1/devices/" + tt.mac + "/latest-firmware-info"); n != tt.requests {
+			t.Errorf("expected %d firmware requests for %s, got %d", tt.requests, tt.mac, n)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package collector
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/charlie-haley/omada_exporter/pkg/api"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type eventsCollector struct {
+	omadaAlertsTotal      *prometheus.Desc
+	omadaEventsTotal      *prometheus.Desc
+	omadaAlertsUnarchived *prometheus.Desc
+	mu                    sync.Mutex
+	cursors               map[logCursorKey]api.LogCursor
+	counts                map[eventKey]float64
+	client                *api.Client
+}
+
+type logCursorKey struct {
+	siteId string
+	kind   string
+}
+
+type eventKey struct {
+	kind     string
+	key      string
+	severity string
+	module   string
+	site     string
+	siteId   string
+}
+
+func (c *eventsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.omadaAlertsTotal
+	ch <- c.omadaEventsTotal
+	ch <- c.omadaAlertsUnarchived
+}
+
+func (c *eventsCollector) Update(ch chan<- prometheus.Metric) error {
+	client := c.client
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var failed error
+	for _, site := range client.Sites {
+		for _, kind := range []string{api.LogAlerts, api.LogEvents} {
+			err := c.poll(site, kind)
+			if err != nil {
+				failed = fmt.Errorf("failed to get %s for site %s: %s", kind, site.Name, err)
+			}
+		}
+
+		unarchived, err := client.GetUnarchivedAlerts(site.Id)
+		if err != nil {
+			failed = fmt.Errorf("failed to get unarchived alerts for site %s: %s", site.Name, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.omadaAlertsUnarchived, prometheus.GaugeValue, unarchived, site.Name, site.Id)
+	}
+
+	for k, v := range c.counts {
+		desc := c.omadaEventsTotal
+		if k.kind == api.LogAlerts {
+			desc = c.omadaAlertsTotal
+		}
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, v, k.key, k.severity, k.module, k.site, k.siteId)
+	}
+
+	return failed
+}
+
+// poll counts the log entries of a site since the last poll, entries logged before the exporter started by the controller's clock aren't counted
+func (c *eventsCollector) poll(site api.Site, kind string) error {
+	key := logCursorKey{siteId: site.Id, kind: kind}
+	cursor, ok := c.cursors[key]
+	if !ok {
+		cursor = api.NewLogCursor(c.client.Now())
+	}
+
+	entries, err := c.client.GetLogs(site.Id, kind, cursor.Start())
+	if err != nil {
+		return err
+	}
+
+	entries, c.cursors[key] = cursor.Next(entries)
+	for _, entry := range entries {
+		c.counts[eventKey{kind, entry.Key, entry.Level, entry.Module, site.Name, site.Id}] += 1
+	}
+
+	return nil
+}
+
+func NewEventsCollector(c *api.Client) *eventsCollector {
+	labels := []string{"key", "severity", "module", "site", "site_id"}
+
+	return &eventsCollector{
+		omadaAlertsTotal: prometheus.NewDesc("omada_alerts_total",
+			"Number of alerts logged by the controller since the exporter started.",
+			labels,
+			nil,
+		),
+		omadaEventsTotal: prometheus.NewDesc("omada_events_total",
+			"Number of events logged by the controller since the exporter started.",
+			labels,
+			nil,
+		),
+		omadaAlertsUnarchived: prometheus.NewDesc("omada_alerts_unarchived",
+			"Number of alerts that haven't been archived.",
+			[]string{"site", "site_id"},
+			nil,
+		),
+		cursors: map[logCursorKey]api.LogCursor{},
+		counts:  map[eventKey]float64{},
+		client:  c,
+	}
+}
@@ -0,0 +1,128 @@
+package collector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/charlie-haley/omada_exporter/pkg/api"
+	"github.com/charlie-haley/omada_exporter/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+)
+
+func TestMain(m *testing.M) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	os.Exit(m.Run())
+}
+
+// fakeController serves canned responses for the web UI endpoints of a controller with a single site
+// "Default", routes are keyed by the path after the controller ID, e.g. /api/v2/sites/s1/devices
+type fakeController struct {
+	*httptest.Server
+	mu       sync.Mutex
+	routes   map[string]string
+	requests map[string]int
+	queries  map[string]url.Values
+}
+
+func newFakeController(t testing.TB, routes map[string]string) *fakeController {
+	f := &fakeController{routes: routes, requests: map[string]int{}, queries: map[string]url.Values{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeController) serve(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/cid")
+
+	f.mu.Lock()
+	f.requests[path] += 1
+	f.queries[path] = r.URL.Query()
+	body, ok := f.routes[path]
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case path == "/api/info":
+		w.Write([]byte(`{"errorCode":0,"result":{"omadacId":"cid"}}`))
+	case path == "/api/v2/loginStatus":
+		w.Write([]byte(`{"errorCode":0,"result":{"login":true}}`))
+	case path == "/api/v2/login":
+		w.Write([]byte(`{"errorCode":0,"result":{"token":"token"}}`))
+	case path == "/api/v2/users/current":
+		w.Write([]byte(`{"errorCode":0,"result":{"privilege":{"sites":[{"name":"Default","key":"s1"}]}}}`))
+	case ok:
+		w.Write([]byte(body))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// count returns the number of requests made to path
+func (f *fakeController) count(path string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[path]
+}
+
+// query returns the query parameters of the last request made to path
+func (f *fakeController) query(path string) url.Values {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.queries[path]
+}
+
+func (f *fakeController) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = map[string]int{}
+}
+
+// newTestClient returns a client configured against the fake controller
+func newTestClient(t testing.TB, f *fakeController, conf config.Config) *api.Client {
+	conf.Host = f.URL
+	conf.AuthMode = config.AuthModeWeb
+	conf.Username = "user"
+	conf.Password = "password"
+	conf.Site = "Default"
+	conf.Timeout = 5
+
+	client, err := api.Configure(&conf)
+	if err != nil {
+		t.Fatalf("failed to configure client: %s", err)
+	}
+	return client
+}
+
+// collect runs a collector's Update and returns the metrics it sent
+func collect(collector Collector) ([]prometheus.Metric, error) {
+	ch := make(chan prometheus.Metric)
+	done := make(chan []prometheus.Metric)
+	go func() {
+		metrics := []prometheus.Metric{}
+		for m := range ch {
+			metrics = append(metrics, m)
+		}
+		done <- metrics
+	}()
+
+	err := collector.Update(ch)
+	close(ch)
+	return <-done, err
+}
+
+// countMetrics returns the number of metrics with the name
+func countMetrics(metrics []prometheus.Metric, name string) int {
+	n := 0
+	for _, m := range metrics {
+		if strings.Contains(m.Desc().String(), `fqName: "`+name+`"`) {
+			n += 1
+		}
+	}
+	return n
+}
@@ -0,0 +1,141 @@
+package collector
+
+import (
+	"fmt"
+
+	"github.com/charlie-haley/omada_exporter/pkg/api"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type gatewayCollector struct {
+	omadaGatewayWanInfo           *prometheus.Desc
+	omadaGatewayWanLinkStatus     *prometheus.Desc
+	omadaGatewayWanInternetStatus *prometheus.Desc
+	omadaGatewayWanLinkSpeedMbps  *prometheus.Desc
+	omadaGatewayWanRxBytes        *prometheus.Desc
+	omadaGatewayWanTxBytes        *prometheus.Desc
+	omadaGatewayWanRxRate         *prometheus.Desc
+	omadaGatewayWanTxRate         *prometheus.Desc
+	omadaGatewayWanLatencyMs      *prometheus.Desc
+	omadaGatewayWanPacketLossPct  *prometheus.Desc
+	client                        *api.Client
+}
+
+func (c *gatewayCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.omadaGatewayWanInfo
+	ch <- c.omadaGatewayWanLinkStatus
+	ch <- c.omadaGatewayWanInternetStatus
+	ch <- c.omadaGatewayWanLinkSpeedMbps
+	ch <- c.omadaGatewayWanRxBytes
+	ch <- c.omadaGatewayWanTxBytes
+	ch <- c.omadaGatewayWanRxRate
+	ch <- c.omadaGatewayWanTxRate
+	ch <- c.omadaGatewayWanLatencyMs
+	ch <- c.omadaGatewayWanPacketLossPct
+}
+
+func (c *gatewayCollector) Update(ch chan<- prometheus.Metric) error {
+	client := c.client
+
+	var failed error
+	for _, site := range client.Sites {
+		devices, err := client.GetDeviceList(site.Id)
+		if err != nil {
+			failed = fmt.Errorf("failed to get devices for site %s: %s", site.Name, err)
+			continue
+		}
+
+		for _, device := range devices {
+			if device.Type != "gateway" {
+				continue
+			}
+
+			gateway, err := client.GetGateway(site.Id, device.Mac)
+			if err != nil {
+				failed = fmt.Errorf("failed to get gateway %s: %s", device.Mac, err)
+				continue
+			}
+
+			for _, p := range gateway.PortStats {
+				if p.Mode != api.GatewayPortModeWan {
+					continue
+				}
+				port := fmt.Sprintf("%.0f", p.Port)
+				loss, _ := p.Loss.Float64()
+				labels := []string{device.Name, device.Mac, port, p.Name, site.Name, site.Id}
+
+				// the address and ISP change with a new DHCP lease or a failover, so they don't start new series of the other metrics
+				ch <- prometheus.MustNewConstMetric(c.omadaGatewayWanInfo, prometheus.GaugeValue, 1, append(labels, p.Ip, p.IspName)...)
+				ch <- prometheus.MustNewConstMetric(c.omadaGatewayWanLinkStatus, prometheus.GaugeValue, p.Status, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaGatewayWanInternetStatus, prometheus.GaugeValue, p.InternetState, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaGatewayWanLinkSpeedMbps, prometheus.GaugeValue, getPortByLinkSpeed(p.Speed), labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaGatewayWanRxBytes, prometheus.CounterValue, p.Rx, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaGatewayWanTxBytes, prometheus.CounterValue, p.Tx, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaGatewayWanRxRate, prometheus.GaugeValue, p.RxRate, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaGatewayWanTxRate, prometheus.GaugeValue, p.TxRate, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaGatewayWanLatencyMs, prometheus.GaugeValue, p.Latency, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaGatewayWanPacketLossPct, prometheus.GaugeValue, loss, labels...)
+			}
+		}
+	}
+
+	return failed
+}
+
+func NewGatewayCollector(c *api.Client) *gatewayCollector {
+	labels := []string{"device", "device_mac", "wan_port", "wan_name", "site", "site_id"}
+
+	return &gatewayCollector{
+		omadaGatewayWanInfo: prometheus.NewDesc("omada_gateway_wan_info",
+			"Address and ISP of the WAN port, always 1.",
+			append(labels, "ip", "isp"),
+			nil,
+		),
+		omadaGatewayWanLinkStatus: prometheus.NewDesc("omada_gateway_wan_link_status",
+			"A boolean representing the link status of the WAN port.",
+			labels,
+			nil,
+		),
+		omadaGatewayWanInternetStatus: prometheus.NewDesc("omada_gateway_wan_internet_status",
+			"A boolean representing whether the WAN port is online and has internet access.",
+			labels,
+			nil,
+		),
+		omadaGatewayWanLinkSpeedMbps: prometheus.NewDesc("omada_gateway_wan_link_speed_mbps",
+			"WAN port link speed in mbps. This is the capability of the connection, not the active throughput.",
+			labels,
+			nil,
+		),
+		omadaGatewayWanRxBytes: prometheus.NewDesc("omada_gateway_wan_rx_bytes",
+			"Bytes received on the WAN port.",
+			labels,
+			nil,
+		),
+		omadaGatewayWanTxBytes: prometheus.NewDesc("omada_gateway_wan_tx_bytes",
+			"Bytes transmitted on the WAN port.",
+			labels,
+			nil,
+		),
+		omadaGatewayWanRxRate: prometheus.NewDesc("omada_gateway_wan_rx_rate",
+			"The rx rate of the WAN port.",
+			labels,
+			nil,
+		),
+		omadaGatewayWanTxRate: prometheus.NewDesc("omada_gateway_wan_tx_rate",
+			"The tx rate of the WAN port.",
+			labels,
+			nil,
+		),
+		omadaGatewayWanLatencyMs: prometheus.NewDesc("omada_gateway_wan_latency_ms",
+			"Latency of the WAN port's internet connection in milliseconds.",
+			labels,
+			nil,
+		),
+		omadaGatewayWanPacketLossPct: prometheus.NewDesc("omada_gateway_wan_packet_loss_pct",
+			"Packet loss of the WAN port's internet connection in percent.",
+			labels,
+			nil,
+		),
+		client: c,
+	}
+}
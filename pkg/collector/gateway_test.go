@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charlie-haley/omada_exporter/pkg/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestGatewayCollectorSkipsSwitchPorts(t *testing.T) {
+	f := newFakeController(t, map[string]string{
+		"/api/v2/sites/s1/devices":           `{"errorCode":0,"result":[{"name":"gw","type":"gateway","mac":"GG"},{"name":"sw","type":"switch","mac":"SS"}]}`,
+		"/api/v2/sites/s1/gateways/GG":       `{"errorCode":0,"result":{"name":"gw","mac":"GG","portStats":[{"port":1,"name":"WAN1","mode":0,"ip":"203.0.113.1","status":1}]}}`,
+		"/api/v2/sites/s1/switches/SS/ports": `{"errorCode":0,"result":[]}`,
+	})
+	c := NewGatewayCollector(newTestClient(t, f, config.Config{}))
+	f.reset()
+
+	metrics, err := collect(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n := countMetrics(metrics, "omada_gateway_wan_link_status"); n != 1 {
+		t.Errorf("expected 1 WAN port, got %d", n)
+	}
+	if n := f.count("/api/v2/sites/s1/switches/SS/ports"); n != 0 {
+		t.Errorf("expected the switch ports not to be fetched, got %d requests", n)
+	}
+}
+
+func TestGatewayCollectorWanInfo(t *testing.T) {
+	f := newFakeController(t, map[string]string{
+		"/api/v2/sites/s1/devices":     `{"errorCode":0,"result":[{"name":"gw","type":"gateway","mac":"GG"}]}`,
+		"/api/v2/sites/s1/gateways/GG": `{"errorCode":0,"result":{"name":"gw","mac":"GG","portStats":[{"port":1,"name":"WAN1","mode":0,"ip":"203.0.113.1","ispName":"ISP","status":1}]}}`,
+	})
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(NewOmadaCollector(map[string]Collector{"gateway": NewGatewayCollector(newTestClient(t, f, config.Config{}))}))
+
+	expected := `
+# HELP omada_gateway_wan_info Address and ISP of the WAN port, always 1.
+# TYPE omada_gateway_wan_info gauge
+omada_gateway_wan_info{device="gw",device_mac="GG",ip="203.0.113.1",isp="ISP",site="Default",site_id="s1",wan_name="WAN1",wan_port="1"} 1
+# HELP omada_gateway_wan_link_status A boolean representing the link status of the WAN port.
+# TYPE omada_gateway_wan_link_status gauge
+omada_gateway_wan_link_status{device="gw",device_mac="GG",site="Default",site_id="s1",wan_name="WAN1",wan_port="1"} 1
+`
+	err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "omada_gateway_wan_info", "omada_gateway_wan_link_status")
+	if err != nil {
+		t.Error(err)
+	}
+}
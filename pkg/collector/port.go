@@ -5,67 +5,152 @@ import (
 
 	"github.com/charlie-haley/omada_exporter/pkg/api"
 	"github.com/prometheus/client_golang/prometheus"
-	log "github.com/rs/zerolog/log"
 )
 
 type portCollector struct {
-	omadaPortPowerWatts    *prometheus.Desc
-	omadaPortLinkStatus    *prometheus.Desc
-	omadaPortLinkSpeedMbps *prometheus.Desc
-	omadaPortLinkRx        *prometheus.Desc
-	omadaPortLinkTx        *prometheus.Desc
-	client                 *api.Client
+	omadaPortInfo             *prometheus.Desc
+	omadaPortPowerWatts       *prometheus.Desc
+	omadaPortLinkStatus       *prometheus.Desc
+	omadaPortLinkSpeedMbps    *prometheus.Desc
+	omadaPortLinkRx           *prometheus.Desc
+	omadaPortLinkTx           *prometheus.Desc
+	omadaPortRxPackets        *prometheus.Desc
+	omadaPortTxPackets        *prometheus.Desc
+	omadaPortRxErrors         *prometheus.Desc
+	omadaPortTxErrors         *prometheus.Desc
+	omadaPortRxDropped        *prometheus.Desc
+	omadaPortTxDropped        *prometheus.Desc
+	omadaPortRxBroadcast      *prometheus.Desc
+	omadaPortTxBroadcast      *prometheus.Desc
+	omadaPortRxMulticast      *prometheus.Desc
+	omadaPortTxMulticast      *prometheus.Desc
+	omadaPortDuplex           *prometheus.Desc
+	omadaPortStpState         *prometheus.Desc
+	omadaPortLoopbackDetected *prometheus.Desc
+	omadaPortUplink           *prometheus.Desc
+	omadaPortPoeClass         *prometheus.Desc
+	omadaPortPoePriority      *prometheus.Desc
+	omadaPortPoeStatus        *prometheus.Desc
+	client                    *api.Client
 }
 
 func (c *portCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.omadaPortInfo
 	ch <- c.omadaPortPowerWatts
 	ch <- c.omadaPortLinkStatus
 	ch <- c.omadaPortLinkSpeedMbps
 	ch <- c.omadaPortLinkRx
 	ch <- c.omadaPortLinkTx
+	ch <- c.omadaPortRxPackets
+	ch <- c.omadaPortTxPackets
+	ch <- c.omadaPortRxErrors
+	ch <- c.omadaPortTxErrors
+	ch <- c.omadaPortRxDropped
+	ch <- c.omadaPortTxDropped
+	ch <- c.omadaPortRxBroadcast
+	ch <- c.omadaPortTxBroadcast
+	ch <- c.omadaPortRxMulticast
+	ch <- c.omadaPortTxMulticast
+	ch <- c.omadaPortDuplex
+	ch <- c.omadaPortStpState
+	ch <- c.omadaPortLoopbackDetected
+	ch <- c.omadaPortUplink
+	ch <- c.omadaPortPoeClass
+	ch <- c.omadaPortPoePriority
+	ch <- c.omadaPortPoeStatus
 }
 
-func (c *portCollector) Collect(ch chan<- prometheus.Metric) {
+func (c *portCollector) Update(ch chan<- prometheus.Metric) error {
 	client := c.client
-	config := c.client.Config
 
-	site := config.Site
-	devices, err := client.GetDevices()
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to get devices")
-		return
-	}
+	var failed error
+	for _, site := range client.Sites {
+		devices, err := client.GetDevices(site.Id)
+		if err != nil {
+			failed = fmt.Errorf("failed to get devices for site %s: %s", site.Name, err)
+			continue
+		}
 
-	for _, device := range devices {
-		// The Omada exporter sometimes returns duplicate ports. e.g an 8 port switch will return 16 ports with identical ports
-		// this causes issues with Prometheus as it tries to register duplicate metrics. A bit of hacky fix, but here we remove
-		// duplicate ports to prevent this error.
-		ports := removeDuplicates(device.Ports)
-		for _, p := range ports {
-			var cHostName, cVendor, cVlanID string
-			linkSpeed := getPortByLinkSpeed(p.PortStatus.LinkSpeed)
-
-			portClient, err := client.GetClientByPort(device.Mac, p.Port)
-			if err != nil {
-				log.Error().Err(err).Msg("Failed to get client by port")
+		// fetch the clients once for the site and join them to the ports, rather than fetching them for every port
+		portClients := map[switchPort]api.NetworkClient{}
+		// the ports are still reported without their client labels when the clients can't be fetched
+		clients, err := client.GetClients(site.Id)
+		if err != nil {
+			failed = fmt.Errorf("failed to get clients for ports of site %s: %s", site.Name, err)
+		}
+		for _, item := range clients {
+			key := switchPort{item.SwitchMac, item.Port}
+			// keep the first client when there are several on a port, e.g. behind an unmanaged switch
+			if _, ok := portClients[key]; !item.Wireless && !ok {
+				portClients[key] = item
 			}
+		}
 
-			port := fmt.Sprintf("%.0f", p.Port)
-			if portClient != nil {
-				cHostName = portClient.HostName
-				cVendor = portClient.Vendor
-				cVlanID = fmt.Sprintf("%.0f", portClient.VlanId)
-			}
+		for _, device := range devices {
+			// The Omada exporter sometimes returns duplicate ports. e.g an 8 port switch will return 16 ports with identical ports
+			// this causes issues with Prometheus as it tries to register duplicate metrics. A bit of hacky fix, but here we remove
+			// duplicate ports to prevent this error.
+			ports := removeDuplicates(device.Ports)
+			for _, p := range ports {
+				var cHostName, cVendor, cVlanID string
+				linkSpeed := getPortByLinkSpeed(p.PortStatus.LinkSpeed)
+
+				port := fmt.Sprintf("%.0f", p.Port)
+				if portClient, ok := portClients[switchPort{device.Mac, p.Port}]; ok {
+					cHostName = portClient.HostName
+					cVendor = portClient.Vendor
+					cVlanID = fmt.Sprintf("%.0f", portClient.VlanId)
+				}
 
-			labels := []string{device.Name, device.Mac, cHostName, cVendor, port, p.Name, p.SwitchMac, p.SwitchId, cVlanID, p.ProfileName, site, client.SiteId}
+				labels := []string{device.Name, device.Mac, cHostName, cVendor, port, p.Name, p.SwitchMac, p.SwitchId, cVlanID, p.ProfileName, site.Name, site.Id}
+				if infoMetrics(client) {
+					ch <- prometheus.MustNewConstMetric(c.omadaPortInfo, prometheus.GaugeValue, 1, labels...)
+					labels = []string{device.Mac, port, site.Id}
+				}
 
-			ch <- prometheus.MustNewConstMetric(c.omadaPortPowerWatts, prometheus.GaugeValue, p.PortStatus.PoePower, labels...)
-			ch <- prometheus.MustNewConstMetric(c.omadaPortLinkStatus, prometheus.GaugeValue, p.PortStatus.LinkStatus, labels...)
-			ch <- prometheus.MustNewConstMetric(c.omadaPortLinkSpeedMbps, prometheus.GaugeValue, linkSpeed, labels...)
-			ch <- prometheus.MustNewConstMetric(c.omadaPortLinkRx, prometheus.CounterValue, p.PortStatus.Rx, labels...)
-			ch <- prometheus.MustNewConstMetric(c.omadaPortLinkTx, prometheus.CounterValue, p.PortStatus.Tx, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaPortPowerWatts, prometheus.GaugeValue, p.PortStatus.PoePower, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaPortLinkStatus, prometheus.GaugeValue, p.PortStatus.LinkStatus, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaPortLinkSpeedMbps, prometheus.GaugeValue, linkSpeed, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaPortLinkRx, prometheus.CounterValue, p.PortStatus.Rx, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaPortLinkTx, prometheus.CounterValue, p.PortStatus.Tx, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaPortRxPackets, prometheus.CounterValue, p.PortStatus.RxPkts, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaPortTxPackets, prometheus.CounterValue, p.PortStatus.TxPkts, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaPortRxErrors, prometheus.CounterValue, p.PortStatus.RxErrPkts, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaPortTxErrors, prometheus.CounterValue, p.PortStatus.TxErrPkts, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaPortRxDropped, prometheus.CounterValue, p.PortStatus.RxDropPkts, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaPortTxDropped, prometheus.CounterValue, p.PortStatus.TxDropPkts, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaPortRxBroadcast, prometheus.CounterValue, p.PortStatus.RxBroadPkts, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaPortTxBroadcast, prometheus.CounterValue, p.PortStatus.TxBroadPkts, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaPortRxMulticast, prometheus.CounterValue, p.PortStatus.RxMultiPkts, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaPortTxMulticast, prometheus.CounterValue, p.PortStatus.TxMultiPkts, labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaPortDuplex, prometheus.GaugeValue, p.PortStatus.Duplex, labels...)
+				stpState := p.StpState()
+				for _, s := range api.StpStates {
+					ch <- prometheus.MustNewConstMetric(c.omadaPortStpState, prometheus.GaugeValue, boolToFloat(s == stpState), append(labels, s)...)
+				}
+				ch <- prometheus.MustNewConstMetric(c.omadaPortLoopbackDetected, prometheus.GaugeValue, boolToFloat(p.PortStatus.LoopbackDetected), labels...)
+				ch <- prometheus.MustNewConstMetric(c.omadaPortUplink, prometheus.GaugeValue, boolToFloat(p.PortStatus.IsUplink), labels...)
+
+				// switches without PoE don't report a budget
+				if device.PoeBudget > 0 {
+					ch <- prometheus.MustNewConstMetric(c.omadaPortPoeClass, prometheus.GaugeValue, p.PortStatus.PoeClass, labels...)
+					ch <- prometheus.MustNewConstMetric(c.omadaPortPoePriority, prometheus.GaugeValue, p.PoePriority, labels...)
+					status := p.PoeStatus()
+					for _, s := range api.PoeStatuses {
+						ch <- prometheus.MustNewConstMetric(c.omadaPortPoeStatus, prometheus.GaugeValue, boolToFloat(s == status), append(labels, s)...)
+					}
+				}
+			}
 		}
 	}
+
+	return failed
+}
+
+// switchPort identifies a port on a switch, used to join clients to the port they're connected to
+type switchPort struct {
+	mac  string
+	port float64
 }
 
 func getPortByLinkSpeed(ls float64) float64 {
@@ -86,6 +171,13 @@ func getPortByLinkSpeed(ls float64) float64 {
 	return 0
 }
 
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 func removeDuplicates(s []api.Port) []api.Port {
 	// create map to track found items
 	found := map[api.Port]bool{}
@@ -104,9 +196,18 @@ func removeDuplicates(s []api.Port) []api.Port {
 }
 
 func NewPortCollector(c *api.Client) *portCollector {
-	labels := []string{"device", "device_mac", "client", "vendor", "switch_port", "name", "switch_mac", "switch_id", "vlan_id", "profile", "site", "site_id"}
+	infoLabels := []string{"device", "device_mac", "client", "vendor", "switch_port", "name", "switch_mac", "switch_id", "vlan_id", "profile", "site", "site_id"}
+	labels := infoLabels
+	if infoMetrics(c) {
+		labels = []string{"device_mac", "switch_port", "site_id"}
+	}
 
 	return &portCollector{
+		omadaPortInfo: prometheus.NewDesc("omada_port_info",
+			"Metadata of the switch port, always 1. Only with --info-metrics, which removes these labels from the other port metrics.",
+			infoLabels,
+			nil,
+		),
 		omadaPortPowerWatts: prometheus.NewDesc("omada_port_power_watts",
 			"The current PoE usage of the port in watts.",
 			labels,
@@ -132,6 +233,91 @@ func NewPortCollector(c *api.Client) *portCollector {
 			labels,
 			nil,
 		),
+		omadaPortRxPackets: prometheus.NewDesc("omada_port_rx_packets",
+			"Packets received on a port.",
+			labels,
+			nil,
+		),
+		omadaPortTxPackets: prometheus.NewDesc("omada_port_tx_packets",
+			"Packets transmitted on a port.",
+			labels,
+			nil,
+		),
+		omadaPortRxErrors: prometheus.NewDesc("omada_port_rx_errors",
+			"Packets received with errors on a port.",
+			labels,
+			nil,
+		),
+		omadaPortTxErrors: prometheus.NewDesc("omada_port_tx_errors",
+			"Packets that failed to transmit with errors on a port.",
+			labels,
+			nil,
+		),
+		omadaPortRxDropped: prometheus.NewDesc("omada_port_rx_dropped",
+			"Received packets dropped on a port.",
+			labels,
+			nil,
+		),
+		omadaPortTxDropped: prometheus.NewDesc("omada_port_tx_dropped",
+			"Transmitted packets dropped on a port.",
+			labels,
+			nil,
+		),
+		omadaPortRxBroadcast: prometheus.NewDesc("omada_port_rx_broadcast_packets",
+			"Broadcast packets received on a port.",
+			labels,
+			nil,
+		),
+		omadaPortTxBroadcast: prometheus.NewDesc("omada_port_tx_broadcast_packets",
+			"Broadcast packets transmitted on a port.",
+			labels,
+			nil,
+		),
+		omadaPortRxMulticast: prometheus.NewDesc("omada_port_rx_multicast_packets",
+			"Multicast packets received on a port.",
+			labels,
+			nil,
+		),
+		omadaPortTxMulticast: prometheus.NewDesc("omada_port_tx_multicast_packets",
+			"Multicast packets transmitted on a port.",
+			labels,
+			nil,
+		),
+		omadaPortDuplex: prometheus.NewDesc("omada_port_duplex",
+			"The duplex mode of the port, 1 for half and 2 for full duplex.",
+			labels,
+			nil,
+		),
+		omadaPortStpState: prometheus.NewDesc("omada_port_stp_state",
+			"The spanning tree state of the port, 1 for the current state of forwarding, discarding or disabled when the link is down.",
+			append(labels, "state"),
+			nil,
+		),
+		omadaPortLoopbackDetected: prometheus.NewDesc("omada_port_loopback_detected",
+			"A boolean representing whether a loop has been detected on the port.",
+			labels,
+			nil,
+		),
+		omadaPortUplink: prometheus.NewDesc("omada_port_uplink",
+			"A boolean representing whether the port is the uplink of the switch.",
+			labels,
+			nil,
+		),
+		omadaPortPoeClass: prometheus.NewDesc("omada_port_poe_class",
+			"The PoE class of the powered device connected to the port.",
+			labels,
+			nil,
+		),
+		omadaPortPoePriority: prometheus.NewDesc("omada_port_poe_priority",
+			"The PoE priority of the port, 0 is the highest priority.",
+			labels,
+			nil,
+		),
+		omadaPortPoeStatus: prometheus.NewDesc("omada_port_poe_status",
+			"The PoE status of the port, 1 for the current status of delivering, searching, overload or disabled.",
+			append(labels, "status"),
+			nil,
+		),
 		client: c,
 	}
 }
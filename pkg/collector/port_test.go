@@ -0,0 +1,78 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/charlie-haley/omada_exporter/pkg/config"
+)
+
+// portRoutes returns the routes of a site with the given number of switches, each with 24 ports and a client on every port
+func portRoutes(switches int) map[string]string {
+	devices, clients := []string{}, []string{}
+	routes := map[string]string{}
+	for s := 0; s < switches; s++ {
+		mac := fmt.Sprintf("AA-AA-AA-AA-AA-%02X", s)
+		devices = append(devices, fmt.Sprintf(`{"name":"sw%d","type":"switch","mac":"%s"}`, s, mac))
+
+		ports := []string{}
+		for p := 1; p <= 24; p++ {
+			ports = append(ports, fmt.Sprintf(`{"name":"Port%d","port":%d,"switchMac":"%s","portStatus":{"linkStatus":1,"linkSpeed":3}}`, p, p, mac))
+			clients = append(clients, fmt.Sprintf(`{"name":"c%d-%d","mac":"CC-CC-CC-CC-%02X-%02X","switchMac":"%s","port":%d,"vid":1}`, s, p, s, p, mac, p))
+		}
+		routes["/api/v2/sites/s1/switches/"+mac+"/ports"] = `{"errorCode":0,"result":[` + strings.Join(ports, ",") + `]}`
+	}
+	routes["/api/v2/sites/s1/devices"] = `{"errorCode":0,"result":[` + strings.Join(devices, ",") + `]}`
+	routes["/api/v2/sites/s1/clients"] = `{"errorCode":0,"result":{"data":[` + strings.Join(clients, ",") + `]}}`
+	return routes
+}
+
+func TestPortCollectorFetchesClientsOnce(t *testing.T) {
+	f := newFakeController(t, portRoutes(4))
+	c := NewPortCollector(newTestClient(t, f, config.Config{}))
+	f.reset()
+
+	metrics, err := collect(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(metrics) == 0 {
+		t.Fatal("expected port metrics")
+	}
+	if n := f.count("/api/v2/sites/s1/clients"); n != 1 {
+		t.Errorf("expected 1 request to clients for 96 ports, got %d", n)
+	}
+}
+
+func TestPortCollectorFailsWithoutClients(t *testing.T) {
+	routes := portRoutes(1)
+	delete(routes, "/api/v2/sites/s1/clients")
+	f := newFakeController(t, routes)
+	c := NewPortCollector(newTestClient(t, f, config.Config{}))
+
+	metrics, err := collect(c)
+	if err == nil {
+		t.Error("expected an error when the clients can't be fetched")
+	}
+	if len(metrics) == 0 {
+		t.Error("expected the port metrics to still be reported")
+	}
+}
+
+func BenchmarkPortCollector(b *testing.B) {
+	f := newFakeController(b, portRoutes(20))
+	c := NewPortCollector(newTestClient(b, f, config.Config{}))
+	f.reset()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := collect(c)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(f.count("/api/v2/sites/s1/clients"))/float64(b.N), "client-requests/scrape")
+}
@@ -0,0 +1,105 @@
+package collector
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/charlie-haley/omada_exporter/pkg/api"
+)
+
+// wireless clients not seen for roamForget are forgotten, so a client which comes back later on
+// another AP isn't counted as a roam, and at most roamMaxClients are remembered
+const (
+	roamForget     = time.Hour
+	roamMaxClients = 50000
+)
+
+// RoamTracker remembers the AP each wireless client was last seen on, and counts the roams between APs.
+// It outlives the client collector, so the counts carry over when the config is reloaded.
+type RoamTracker struct {
+	mu      sync.Mutex
+	clients map[roamClientKey]roamClient
+	counts  map[roamKey]float64
+}
+
+type roamClientKey struct {
+	siteId string
+	mac    string
+}
+
+type roamClient struct {
+	ap   string
+	seen time.Time
+}
+
+type roamKey struct {
+	from   string
+	to     string
+	site   string
+	siteId string
+}
+
+func NewRoamTracker() *RoamTracker {
+	return &RoamTracker{
+		clients: map[roamClientKey]roamClient{},
+		counts:  map[roamKey]float64{},
+	}
+}
+
+// observe records the AP of each wireless client of a site, counting a roam for every client
+// that is now on a different AP than the last time it was seen
+func (t *RoamTracker) observe(site api.Site, clients []api.NetworkClient, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, item := range clients {
+		if !item.Wireless || item.ApName == "" {
+			continue
+		}
+		key := roamClientKey{siteId: site.Id, mac: item.Mac}
+		// a client gone for longer than roamForget is new, even if it hasn't been pruned yet
+		if last, ok := t.clients[key]; ok && now.Sub(last.seen) <= roamForget && last.ap != item.ApName {
+			t.counts[roamKey{last.ap, item.ApName, site.Name, site.Id}] += 1
+		}
+		t.clients[key] = roamClient{ap: item.ApName, seen: now}
+	}
+
+	t.prune(now)
+}
+
+// prune forgets the clients which haven't been seen for roamForget, then the least recently
+// seen clients while there are more than roamMaxClients
+func (t *RoamTracker) prune(now time.Time) {
+	for key, client := range t.clients {
+		if now.Sub(client.seen) > roamForget {
+			delete(t.clients, key)
+		}
+	}
+	if len(t.clients) <= roamMaxClients {
+		return
+	}
+
+	keys := make([]roamClientKey, 0, len(t.clients))
+	for key := range t.clients {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return t.clients[keys[i]].seen.Before(t.clients[keys[j]].seen)
+	})
+	for _, key := range keys[:len(keys)-roamMaxClients] {
+		delete(t.clients, key)
+	}
+}
+
+// roams returns a copy of the roam counts, keyed by the pair of APs
+func (t *RoamTracker) roams() map[roamKey]float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	counts := make(map[roamKey]float64, len(t.counts))
+	for k, v := range t.counts {
+		counts[k] = v
+	}
+	return counts
+}
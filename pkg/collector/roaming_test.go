@@ -0,0 +1,122 @@
+package collector
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/charlie-haley/omada_exporter/pkg/api"
+)
+
+func TestRoamTrackerObserve(t *testing.T) {
+	site := api.Site{Name: "Default", Id: "s1"}
+	start := time.Unix(1700000000, 0)
+	wireless := func(mac string, ap string) api.NetworkClient {
+		return api.NetworkClient{Mac: mac, ApName: ap, Wireless: true}
+	}
+
+	tests := []struct {
+		name  string
+		scans [][]api.NetworkClient
+		// the gap between the scans
+		interval time.Duration
+		roams    map[roamKey]float64
+	}{
+		{
+			"no roams",
+			[][]api.NetworkClient{{wireless("a", "ap1")}, {wireless("a", "ap1")}},
+			time.Minute,
+			map[roamKey]float64{},
+		},
+		{
+			"roam",
+			[][]api.NetworkClient{{wireless("a", "ap1")}, {wireless("a", "ap2")}},
+			time.Minute,
+			map[roamKey]float64{{"ap1", "ap2", "Default", "s1"}: 1},
+		},
+		{
+			"roam back",
+			[][]api.NetworkClient{{wireless("a", "ap1")}, {wireless("a", "ap2")}, {wireless("a", "ap1")}},
+			time.Minute,
+			map[roamKey]float64{{"ap1", "ap2", "Default", "s1"}: 1, {"ap2", "ap1", "Default", "s1"}: 1},
+		},
+		{
+			"several clients",
+			[][]api.NetworkClient{{wireless("a", "ap1"), wireless("b", "ap1")}, {wireless("a", "ap2"), wireless("b", "ap2")}},
+			time.Minute,
+			map[roamKey]float64{{"ap1", "ap2", "Default", "s1"}: 2},
+		},
+		{
+			"wired clients and clients without an AP ignored",
+			[][]api.NetworkClient{{{Mac: "a", ApName: "ap1"}, wireless("b", "")}, {{Mac: "a", ApName: "ap2"}, wireless("b", "ap2")}},
+			time.Minute,
+			map[roamKey]float64{},
+		},
+		{
+			"client that left and came back later",
+			[][]api.NetworkClient{{wireless("a", "ap1")}, {}, {wireless("a", "ap2")}},
+			roamForget,
+			map[roamKey]float64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewRoamTracker()
+			now := start
+			for _, clients := range tt.scans {
+				tracker.observe(site, clients, now)
+				now = now.Add(tt.interval)
+			}
+
+			roams := tracker.roams()
+			if len(roams) != len(tt.roams) {
+				t.Fatalf("expected roams %v, got %v", tt.roams, roams)
+			}
+			for k, v := range tt.roams {
+				if roams[k] != v {
+					t.Errorf("expected %v roams for %v, got %v", v, k, roams[k])
+				}
+			}
+		})
+	}
+}
+
+func TestRoamTrackerPrune(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name string
+		seen map[string]time.Duration
+		// the number of clients on another site seen more recently than any of seen
+		newer int
+		kept  []string
+	}{
+		{"recent clients kept", map[string]time.Duration{"a": 0, "b": roamForget}, 0, []string{"a", "b"}},
+		{"old clients forgotten", map[string]time.Duration{"a": 0, "b": roamForget + time.Second}, 0, []string{"a"}},
+		{"least recent forgotten over the limit", map[string]time.Duration{"a": time.Second, "b": 0}, roamMaxClients - 1, []string{"b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewRoamTracker()
+			for mac, ago := range tt.seen {
+				tracker.clients[roamClientKey{"s1", mac}] = roamClient{ap: "ap1", seen: now.Add(-ago)}
+			}
+			for i := 0; i < tt.newer; i++ {
+				tracker.clients[roamClientKey{"s2", strconv.Itoa(i)}] = roamClient{ap: "ap1", seen: now.Add(time.Millisecond)}
+			}
+
+			tracker.prune(now)
+
+			for _, mac := range tt.kept {
+				if _, ok := tracker.clients[roamClientKey{"s1", mac}]; !ok {
+					t.Errorf("expected client %s to be kept", mac)
+				}
+			}
+			if expected := len(tt.kept) + tt.newer; len(tracker.clients) != expected {
+				t.Errorf("expected %d clients kept, got %d", expected, len(tracker.clients))
+			}
+		})
+	}
+}
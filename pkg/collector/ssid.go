@@ -0,0 +1,150 @@
+package collector
+
+import (
+	"fmt"
+
+	"github.com/charlie-haley/omada_exporter/pkg/api"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type ssidCollector struct {
+	omadaSsidInfo             *prometheus.Desc
+	omadaSsidClients          *prometheus.Desc
+	omadaSsidBandClients      *prometheus.Desc
+	omadaSsidTrafficDownBytes *prometheus.Desc
+	omadaSsidTrafficUpBytes   *prometheus.Desc
+	client                    *api.Client
+}
+
+func (c *ssidCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.omadaSsidInfo
+	ch <- c.omadaSsidClients
+	ch <- c.omadaSsidBandClients
+	ch <- c.omadaSsidTrafficDownBytes
+	ch <- c.omadaSsidTrafficUpBytes
+}
+
+type ssidTotals struct {
+	clients     float64
+	bands       map[string]float64
+	trafficDown float64
+	trafficUp   float64
+}
+
+func (c *ssidCollector) Update(ch chan<- prometheus.Metric) error {
+	client := c.client
+
+	var failed error
+	for _, site := range client.Sites {
+		wlans, err := client.GetWlans(site.Id)
+		if err != nil {
+			failed = fmt.Errorf("failed to get wlans for site %s: %s", site.Name, err)
+			continue
+		}
+		clients, err := client.GetClients(site.Id)
+		if err != nil {
+			failed = fmt.Errorf("failed to get clients for site %s: %s", site.Name, err)
+			continue
+		}
+
+		totals := map[string]*ssidTotals{}
+		for _, item := range clients {
+			if !item.Wireless {
+				continue
+			}
+			t, ok := totals[item.Ssid]
+			if !ok {
+				t = &ssidTotals{bands: map[string]float64{}}
+				totals[item.Ssid] = t
+			}
+			t.clients += 1
+			t.bands[api.Band(int(item.RadioId))] += 1
+			t.trafficDown += item.TrafficDown
+			t.trafficUp += item.TrafficUp
+		}
+
+		// clients only report the name of their SSID, so the client metrics are per SSID name across WLAN groups
+		names := []string{}
+		ssidBands := map[string]map[string]float64{}
+		for _, wlan := range wlans {
+			ssids, err := client.GetSsids(site.Id, wlan.Id)
+			if err != nil {
+				failed = fmt.Errorf("failed to get ssids for wlan %s: %s", wlan.Name, err)
+				continue
+			}
+
+			for _, ssid := range ssids {
+				vlanId := ""
+				if ssid.VlanEnable {
+					vlanId = fmt.Sprintf("%.0f", ssid.VlanId)
+				}
+				ch <- prometheus.MustNewConstMetric(c.omadaSsidInfo, prometheus.GaugeValue, 1,
+					ssid.Name, wlan.Name, site.Name, site.Id, fmt.Sprintf("%t", ssid.Enabled()), fmt.Sprintf("%t", ssid.Broadcast), ssid.SecurityMode(), vlanId)
+
+				bands, ok := ssidBands[ssid.Name]
+				if !ok {
+					bands = map[string]float64{}
+					ssidBands[ssid.Name] = bands
+					names = append(names, ssid.Name)
+				}
+				for _, band := range ssid.Bands() {
+					bands[band] = 0
+				}
+			}
+		}
+
+		for _, name := range names {
+			t, ok := totals[name]
+			if !ok {
+				t = &ssidTotals{bands: map[string]float64{}}
+			}
+			bands := ssidBands[name]
+			for band, v := range t.bands {
+				bands[band] = v
+			}
+			labels := []string{name, site.Name, site.Id}
+
+			ch <- prometheus.MustNewConstMetric(c.omadaSsidClients, prometheus.GaugeValue, t.clients, labels...)
+			for band, v := range bands {
+				ch <- prometheus.MustNewConstMetric(c.omadaSsidBandClients, prometheus.GaugeValue, v, append(labels, band)...)
+			}
+			ch <- prometheus.MustNewConstMetric(c.omadaSsidTrafficDownBytes, prometheus.GaugeValue, t.trafficDown, labels...)
+			ch <- prometheus.MustNewConstMetric(c.omadaSsidTrafficUpBytes, prometheus.GaugeValue, t.trafficUp, labels...)
+		}
+	}
+
+	return failed
+}
+
+func NewSsidCollector(c *api.Client) *ssidCollector {
+	labels := []string{"ssid", "site", "site_id"}
+
+	return &ssidCollector{
+		omadaSsidInfo: prometheus.NewDesc("omada_ssid_info",
+			"Information about the SSID, always 1.",
+			[]string{"ssid", "wlan", "site", "site_id", "enabled", "broadcast", "security", "vlan_id"},
+			nil,
+		),
+		omadaSsidClients: prometheus.NewDesc("omada_ssid_clients",
+			"Number of clients connected to the SSID in every WLAN group using its name.",
+			labels,
+			nil,
+		),
+		omadaSsidBandClients: prometheus.NewDesc("omada_ssid_band_clients",
+			"Number of clients connected to the SSID by radio band in every WLAN group using its name.",
+			append(labels, "band"),
+			nil,
+		),
+		omadaSsidTrafficDownBytes: prometheus.NewDesc("omada_ssid_traffic_down_bytes",
+			"Total bytes received by the clients connected to the SSID.",
+			labels,
+			nil,
+		),
+		omadaSsidTrafficUpBytes: prometheus.NewDesc("omada_ssid_traffic_up_bytes",
+			"Total bytes sent by the clients connected to the SSID.",
+			labels,
+			nil,
+		),
+		client: c,
+	}
+}
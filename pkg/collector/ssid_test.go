@@ -0,0 +1,91 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charlie-haley/omada_exporter/pkg/config"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// ssidClients returns the value of each omada_ssid_clients series by its ssid label
+func ssidClients(t *testing.T, c *ssidCollector) map[string]float64 {
+	metrics, err := collect(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	result := map[string]float64{}
+	for _, m := range metrics {
+		if !strings.Contains(m.Desc().String(), `"omada_ssid_clients"`) {
+			continue
+		}
+		var out dto.Metric
+		m.Write(&out)
+		for _, l := range out.Label {
+			if l.GetName() == "ssid" {
+				if _, ok := result[l.GetValue()]; ok {
+					t.Errorf("expected one series for ssid %s", l.GetValue())
+				}
+				result[l.GetValue()] = out.GetGauge().GetValue()
+			}
+		}
+	}
+	return result
+}
+
+func TestSsidCollectorClients(t *testing.T) {
+	tests := []struct {
+		name    string
+		wlans   map[string]string
+		clients string
+		totals  map[string]float64
+	}{
+		{
+			"single wlan group",
+			map[string]string{"w1": `[{"id":"a","name":"home","band":3}]`},
+			`[{"mac":"C1","wireless":true,"ssid":"home"},{"mac":"C2","wireless":true,"ssid":"home"},{"mac":"C3"}]`,
+			map[string]float64{"home": 2},
+		},
+		{
+			"ssid name in several wlan groups",
+			map[string]string{
+				"w1": `[{"id":"a","name":"home","band":3}]`,
+				"w2": `[{"id":"b","name":"home","band":1},{"id":"c","name":"guest","band":1}]`,
+			},
+			`[{"mac":"C1","wireless":true,"ssid":"home"},{"mac":"C2","wireless":true,"ssid":"home"},{"mac":"C3","wireless":true,"ssid":"guest"}]`,
+			map[string]float64{"home": 2, "guest": 1},
+		},
+		{
+			"no clients",
+			map[string]string{"w1": `[{"id":"a","name":"home","band":3}]`},
+			`[]`,
+			map[string]float64{"home": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes := map[string]string{
+				"/api/v2/sites/s1/clients": `{"errorCode":0,"result":{"data":` + tt.clients + `}}`,
+			}
+			wlans := []string{}
+			for id, ssids := range tt.wlans {
+				wlans = append(wlans, `{"id":"`+id+`","name":"`+id+`"}`)
+				routes["/api/v2/sites/s1/setting/wlans/"+id+"/ssids"] = `{"errorCode":0,"result":{"data":` + ssids + `,"totalRows":0}}`
+			}
+			routes["/api/v2/sites/s1/setting/wlans"] = `{"errorCode":0,"result":[` + strings.Join(wlans, ",") + `]}`
+			f := newFakeController(t, routes)
+			c := NewSsidCollector(newTestClient(t, f, config.Config{}))
+
+			totals := ssidClients(t, c)
+			if len(totals) != len(tt.totals) {
+				t.Errorf("expected %v, got %v", tt.totals, totals)
+			}
+			for ssid, expected := range tt.totals {
+				if got, ok := totals[ssid]; !ok || got != expected {
+					t.Errorf("expected %v clients for %s, got %v", expected, ssid, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"fmt"
+
+	"github.com/charlie-haley/omada_exporter/pkg/api"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type topologyCollector struct {
+	omadaDeviceUplinkInfo *prometheus.Desc
+	omadaPortNeighborInfo *prometheus.Desc
+	client                *api.Client
+}
+
+func (c *topologyCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.omadaDeviceUplinkInfo
+	ch <- c.omadaPortNeighborInfo
+}
+
+func (c *topologyCollector) Update(ch chan<- prometheus.Metric) error {
+	client := c.client
+
+	var failed error
+	for _, site := range client.Sites {
+		devices, err := client.GetDeviceList(site.Id)
+		if err != nil {
+			failed = fmt.Errorf("failed to get devices for site %s: %s", site.Name, err)
+			continue
+		}
+
+		for _, device := range devices {
+			if device.UplinkDeviceMac != "" {
+				uplinkType, uplinkPort := UplinkType(device), ""
+				if !device.WirelessLinked {
+					uplinkPort = fmt.Sprintf("%.0f", device.UplinkDevicePort)
+				}
+				ch <- prometheus.MustNewConstMetric(c.omadaDeviceUplinkInfo, prometheus.GaugeValue, 1,
+					device.Name, device.Mac, device.UplinkDeviceMac, uplinkPort, uplinkType, site.Name, site.Id)
+			}
+
+			if device.Type != "switch" {
+				continue
+			}
+			neighbors, err := client.GetLldpNeighbors(site.Id, device.Mac)
+			if err != nil {
+				failed = fmt.Errorf("failed to get lldp neighbors for switch %s: %s", device.Mac, err)
+				continue
+			}
+			for _, n := range neighbors {
+				ch <- prometheus.MustNewConstMetric(c.omadaPortNeighborInfo, prometheus.GaugeValue, 1,
+					device.Name, device.Mac, fmt.Sprintf("%.0f", n.Port), n.SystemName, n.ChassisId, n.PortId, n.PortDescription, n.ManagementAddress, site.Name, site.Id)
+			}
+		}
+	}
+
+	return failed
+}
+
+// UplinkType returns how a device is connected to its uplink, either wired or mesh
+func UplinkType(device api.Device) string {
+	if device.WirelessLinked {
+		return "mesh"
+	}
+	return "wired"
+}
+
+func NewTopologyCollector(c *api.Client) *topologyCollector {
+	return &topologyCollector{
+		omadaDeviceUplinkInfo: prometheus.NewDesc("omada_device_uplink_info",
+			"The omada device a device is connected through, always 1.",
+			[]string{"device", "device_mac", "uplink_mac", "uplink_port", "type", "site", "site_id"},
+			nil,
+		),
+		omadaPortNeighborInfo: prometheus.NewDesc("omada_port_neighbor_info",
+			"A neighbor discovered over LLDP on a switch port, always 1.",
+			[]string{"device", "device_mac", "switch_port", "neighbor_name", "neighbor_chassis_id", "neighbor_port", "neighbor_port_description", "neighbor_ip", "site", "site_id"},
+			nil,
+		),
+		client: c,
+	}
+}
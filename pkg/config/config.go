@@ -1,14 +1,81 @@
 package config
 
+import "time"
+
+const (
+	// AuthModeWeb logs in to the web UI endpoints with a username and password
+	AuthModeWeb = "web"
+	// AuthModeOpenAPI uses the official OpenAPI with client credentials
+	AuthModeOpenAPI = "openapi"
+)
+
+const (
+	// ForwardSinkWebhook posts events as JSON to a URL
+	ForwardSinkWebhook = "webhook"
+	// ForwardSinkStdout writes events to stdout as JSON log lines
+	ForwardSinkStdout = "stdout"
+	// ForwardSinkLoki pushes events to the Loki push API
+	ForwardSinkLoki = "loki"
+)
+
+// ClientLabels are the labels of the per-client metrics, --clients.labels can drop every one but mac and
+// either site or site_id
+var ClientLabels = []string{"client", "vendor", "ip", "mac", "host_name", "site", "site_id", "connection_mode", "wifi_mode", "ap_name", "ssid", "vlan_id", "switch_port", "switch_name", "switch_mac"}
+
 type Config struct {
 	Host                     string
+	AuthMode                 string
 	Username                 string
 	Password                 string
+	ClientId                 string
+	ClientSecret             string
 	Port                     string
+	ListenAddress            string
 	Site                     string
+	AllSites                 bool
+	IncludeSites             []string
+	ExcludeSites             []string
+	ConfigFile               string
 	LogLevel                 string
 	Timeout                  int
 	Insecure                 bool
+	PollInterval             time.Duration
+	ForwardSink              string
+	ForwardURL               string
+	ForwardInterval          time.Duration
+	ForwardCursorFile        string
+	ClientHistory            bool
+	ClientLabels             []string
+	ClientMaxSeries          int
+	ClientAggregateOnly      bool
+	ClientAllowMacs          []string
+	InfoMetrics              bool
 	GoCollectorDisabled      bool
 	ProcessCollectorDisabled bool
+	Collectors               map[string]bool
+	Labels                   map[string]string
+	Controllers              map[string]Controller
+	Modules                  map[string]Module
+}
+
+// ValidClientLabels returns whether labels are distinct per-client labels which include mac and either site or site_id,
+// so a client on several sites keeps a series per site, empty keeps every label
+func ValidClientLabels(labels []string) bool {
+	if len(labels) == 0 {
+		return true
+	}
+	seen := map[string]bool{}
+	for _, label := range labels {
+		found := false
+		for _, l := range ClientLabels {
+			if l == label {
+				found = true
+			}
+		}
+		if !found || seen[label] {
+			return false
+		}
+		seen[label] = true
+	}
+	return seen["mac"] && (seen["site"] || seen["site_id"])
 }
@@ -0,0 +1,28 @@
+package config
+
+import "testing"
+
+func TestValidClientLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels []string
+		valid  bool
+	}{
+		{"every label", nil, true},
+		{"mac and site", []string{"mac", "site"}, true},
+		{"mac and site_id", []string{"mac", "site_id"}, true},
+		{"subset", []string{"client", "mac", "site", "site_id", "ap_name"}, true},
+		{"without mac", []string{"client", "site", "site_id"}, false},
+		{"without site and site_id", []string{"client", "mac"}, false},
+		{"unknown label", []string{"mac", "site", "uptime"}, false},
+		{"duplicate label", []string{"mac", "site", "mac"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if valid := ValidClientLabels(tt.labels); valid != tt.valid {
+				t.Errorf("expected %v for %q, got %v", tt.valid, tt.labels, valid)
+			}
+		})
+	}
+}
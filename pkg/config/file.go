@@ -0,0 +1,331 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+)
+
+// File is the structure of the configuration file passed with --config.file,
+// either YAML or TOML depending on the file extension
+type File struct {
+	LogLevel     string                `yaml:"log_level" toml:"log_level"`
+	PollInterval time.Duration         `yaml:"poll_interval" toml:"poll_interval"`
+	InfoMetrics  bool                  `yaml:"info_metrics" toml:"info_metrics"`
+	Web          WebFile               `yaml:"web" toml:"web"`
+	Controller   ControllerFile        `yaml:"controller" toml:"controller"`
+	Sites        SitesFile             `yaml:"sites" toml:"sites"`
+	Forward      ForwardFile           `yaml:"forward" toml:"forward"`
+	Clients      ClientsFile           `yaml:"clients" toml:"clients"`
+	Collectors   map[string]bool       `yaml:"collectors" toml:"collectors"`
+	Labels       map[string]string     `yaml:"labels" toml:"labels"`
+	Controllers  map[string]Controller `yaml:"controllers" toml:"controllers"`
+	Modules      map[string]Module     `yaml:"modules" toml:"modules"`
+
+	path  string
+	lines map[string]int
+}
+
+type WebFile struct {
+	ListenAddress string `yaml:"listen_address" toml:"listen_address"`
+}
+
+type ControllerFile struct {
+	Host         string `yaml:"host" toml:"host"`
+	AuthMode     string `yaml:"auth_mode" toml:"auth_mode"`
+	Username     string `yaml:"username" toml:"username"`
+	Password     string `yaml:"password" toml:"password"`
+	ClientId     string `yaml:"client_id" toml:"client_id"`
+	ClientSecret string `yaml:"client_secret" toml:"client_secret"`
+	Insecure     bool   `yaml:"insecure" toml:"insecure"`
+	Timeout      int    `yaml:"timeout" toml:"timeout"`
+}
+
+type SitesFile struct {
+	Name    string   `yaml:"name" toml:"name"`
+	All     bool     `yaml:"all" toml:"all"`
+	Include []string `yaml:"include" toml:"include"`
+	Exclude []string `yaml:"exclude" toml:"exclude"`
+}
+
+type ForwardFile struct {
+	Sink       string        `yaml:"sink" toml:"sink"`
+	URL        string        `yaml:"url" toml:"url"`
+	Interval   time.Duration `yaml:"interval" toml:"interval"`
+	CursorFile string        `yaml:"cursor_file" toml:"cursor_file"`
+}
+
+type ClientsFile struct {
+	History       bool     `yaml:"history" toml:"history"`
+	Labels        []string `yaml:"labels" toml:"labels"`
+	MaxSeries     int      `yaml:"max_series" toml:"max_series"`
+	AggregateOnly bool     `yaml:"aggregate_only" toml:"aggregate_only"`
+	AllowMacs     []string `yaml:"allow_macs" toml:"allow_macs"`
+}
+
+// Controller is a named controller which can be passed as the target to the /probe endpoint
+type Controller struct {
+	Host   string `yaml:"host" toml:"host"`
+	Module string `yaml:"module" toml:"module"`
+	Site   string `yaml:"site" toml:"site"`
+}
+
+// Module holds the credentials used by the /probe endpoint for a controller
+type Module struct {
+	AuthMode     string `yaml:"auth_mode" toml:"auth_mode"`
+	Username     string `yaml:"username" toml:"username"`
+	Password     string `yaml:"password" toml:"password"`
+	ClientId     string `yaml:"client_id" toml:"client_id"`
+	ClientSecret string `yaml:"client_secret" toml:"client_secret"`
+	Insecure     bool   `yaml:"insecure" toml:"insecure"`
+	Timeout      int    `yaml:"timeout" toml:"timeout"`
+	// Targets are the hosts the module can probe as well as --host and the controllers
+	Targets []string `yaml:"targets" toml:"targets"`
+}
+
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %s", err)
+	}
+
+	file := &File{path: path}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		err = file.parseToml(data)
+	default:
+		err = file.parseYaml(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = file.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+func (f *File) parseYaml(data []byte) error {
+	root := yaml.Node{}
+	err := yaml.Unmarshal(data, &root)
+	if err != nil {
+		return f.wrap(strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+
+	// decode strictly so that typos in keys are reported rather than silently ignored
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err = decoder.Decode(f)
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		// yaml reports these as "line N: message", reformat to match the other errors
+		msgs := []string{}
+		for _, msg := range typeErr.Errors {
+			msgs = append(msgs, f.wrap(msg).Error())
+		}
+		return fmt.Errorf("%s", strings.Join(msgs, "\n"))
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return f.wrap(strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+
+	f.lines = map[string]int{}
+	if len(root.Content) > 0 {
+		yamlLines(root.Content[0], "", f.lines)
+	}
+
+	return nil
+}
+
+// yamlLines records the line of every key in the document, keyed by its dotted path
+func yamlLines(node *yaml.Node, prefix string, lines map[string]int) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i].Value
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		lines[key] = node.Content[i].Line
+		yamlLines(node.Content[i+1], key, lines)
+	}
+}
+
+func (f *File) parseToml(data []byte) error {
+	md, err := toml.Decode(string(data), f)
+	if err != nil {
+		return f.wrap(strings.TrimPrefix(err.Error(), "toml: "))
+	}
+
+	f.lines = tomlLines(data)
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		key := undecoded[0].String()
+		return f.errorf(key, "unknown field %q", key)
+	}
+
+	return nil
+}
+
+// tomlLines records the line of every key and table header in the document, keyed by its dotted path
+func tomlLines(data []byte) map[string]int {
+	lines := map[string]int{}
+	table := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "["):
+			table = strings.Trim(line, "[] ")
+			table = strings.ReplaceAll(table, `"`, "")
+			lines[table] = n
+		case strings.Contains(line, "="):
+			key := strings.TrimSpace(strings.SplitN(line, "=", 2)[0])
+			key = strings.ReplaceAll(key, `"`, "")
+			if table != "" {
+				key = table + "." + key
+			}
+			lines[key] = n
+		}
+	}
+	return lines
+}
+
+// wrap prefixes a parser error with the file, the parsers report positions as "line N: message"
+func (f *File) wrap(msg string) error {
+	var line int
+	if n, _ := fmt.Sscanf(msg, "line %d", &line); n == 1 {
+		return fmt.Errorf("%s:%d:%s", f.path, line, msg[strings.Index(msg, ":")+1:])
+	}
+	return fmt.Errorf("%s: %s", f.path, msg)
+}
+
+// errorf returns an error prefixed with the file and the line of key, falling back
+// to the closest parent key found in the document
+func (f *File) errorf(key string, format string, a ...interface{}) error {
+	msg := fmt.Sprintf(format, a...)
+	for k := key; k != ""; {
+		if line, ok := f.lines[k]; ok {
+			return fmt.Errorf("%s:%d: %s", f.path, line, msg)
+		}
+		i := strings.LastIndex(k, ".")
+		if i < 0 {
+			break
+		}
+		k = k[:i]
+	}
+	return fmt.Errorf("%s: %s", f.path, msg)
+}
+
+func (f *File) validate() error {
+	if f.LogLevel != "" {
+		if _, err := zerolog.ParseLevel(f.LogLevel); err != nil {
+			return f.errorf("log_level", "invalid log level %q", f.LogLevel)
+		}
+	}
+	if f.PollInterval < 0 {
+		return f.errorf("poll_interval", "poll_interval must not be negative")
+	}
+	if f.Controller.Host != "" && !hasScheme(f.Controller.Host) {
+		return f.errorf("controller.host", "host %q must include the protocol, e.g. https://", f.Controller.Host)
+	}
+	if !validAuthMode(f.Controller.AuthMode) {
+		return f.errorf("controller.auth_mode", "invalid auth mode %q, must be %q or %q", f.Controller.AuthMode, AuthModeWeb, AuthModeOpenAPI)
+	}
+	if f.Controller.Timeout < 0 {
+		return f.errorf("controller.timeout", "timeout must not be negative")
+	}
+	if f.Sites.All && f.Sites.Name != "" {
+		return f.errorf("sites.name", "name can not be used together with all")
+	}
+	if !ValidForwardSink(f.Forward.Sink) {
+		return f.errorf("forward.sink", "invalid sink %q, must be %q, %q or %q", f.Forward.Sink, ForwardSinkWebhook, ForwardSinkStdout, ForwardSinkLoki)
+	}
+	if (f.Forward.Sink == ForwardSinkWebhook || f.Forward.Sink == ForwardSinkLoki) && f.Forward.URL == "" {
+		return f.errorf("forward", "the %s sink requires a url", f.Forward.Sink)
+	}
+	if f.Forward.Interval < 0 {
+		return f.errorf("forward.interval", "interval must not be negative")
+	}
+	if !ValidClientLabels(f.Clients.Labels) {
+		return f.errorf("clients.labels", "invalid labels %q, must be distinct, include mac and site or site_id, and be any of %s", f.Clients.Labels, strings.Join(ClientLabels, ", "))
+	}
+	if f.Clients.MaxSeries < 0 {
+		return f.errorf("clients.max_series", "max_series must not be negative")
+	}
+	for name, m := range f.Modules {
+		if !validAuthMode(m.AuthMode) {
+			return f.errorf("modules."+name+".auth_mode", "invalid auth mode %q, must be %q or %q", m.AuthMode, AuthModeWeb, AuthModeOpenAPI)
+		}
+		if m.AuthMode == AuthModeOpenAPI && (m.ClientId == "" || m.ClientSecret == "") {
+			return f.errorf("modules."+name, "module %q requires a client_id and client_secret", name)
+		}
+		if m.AuthMode != AuthModeOpenAPI && (m.Username == "" || m.Password == "") {
+			return f.errorf("modules."+name, "module %q requires a username and password", name)
+		}
+		if m.Timeout < 0 {
+			return f.errorf("modules."+name+".timeout", "timeout must not be negative")
+		}
+		for _, target := range m.Targets {
+			if !hasScheme(target) {
+				return f.errorf("modules."+name+".targets", "target %q must include the protocol, e.g. https://", target)
+			}
+		}
+	}
+	for name, c := range f.Controllers {
+		if c.Host == "" {
+			return f.errorf("controllers."+name, "controller %q requires a host", name)
+		}
+		if !hasScheme(c.Host) {
+			return f.errorf("controllers."+name+".host", "host %q must include the protocol, e.g. https://", c.Host)
+		}
+		if _, ok := f.Modules[c.Module]; c.Module != "" && c.Module != "default" && !ok {
+			return f.errorf("controllers."+name+".module", "unknown module %q", c.Module)
+		}
+	}
+	return nil
+}
+
+// ValidateCollectors checks that every collector in the file is one of names
+func (f *File) ValidateCollectors(names []string) error {
+	for collector := range f.Collectors {
+		found := false
+		for _, name := range names {
+			if name == collector {
+				found = true
+			}
+		}
+		if !found {
+			return f.errorf("collectors."+collector, "unknown collector %q, must be one of %s", collector, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+func validAuthMode(mode string) bool {
+	return mode == "" || mode == AuthModeWeb || mode == AuthModeOpenAPI
+}
+
+// ValidForwardSink returns whether sink is one of the event forwarding sinks, or empty to disable forwarding
+func ValidForwardSink(sink string) bool {
+	return sink == "" || sink == ForwardSinkWebhook || sink == ForwardSinkStdout || sink == ForwardSinkLoki
+}
+
+func hasScheme(host string) bool {
+	return strings.HasPrefix(host, "http://") || strings.HasPrefix(host, "https://")
+}
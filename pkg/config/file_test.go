@@ -0,0 +1,130 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, []byte(strings.TrimLeft(content, "\n")), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		err     string
+	}{
+		{"yaml syntax", "config.yaml", `
+controller:
+  host: https://omada
+ username: admin
+`, ":2: did not find expected key"},
+		{"yaml unknown field", "config.yaml", `
+controller:
+  host: https://omada
+  hots: https://omada
+`, ":3: field hots not found"},
+		{"yaml invalid value", "config.yaml", `
+log_level: info
+controller:
+  auth_mode: token
+`, `:3: invalid auth mode "token"`},
+		{"yaml nested key", "config.yaml", `
+forward:
+  sink: webhook
+  interval: 30s
+`, ":1: the webhook sink requires a url"},
+		{"yaml module", "config.yaml", `
+modules:
+  branch:
+    username: admin
+    password: secret
+    timeout: -1
+`, `:5: timeout must not be negative`},
+		{"yaml module target", "config.yaml", `
+modules:
+  branch:
+    username: admin
+    password: secret
+    targets: [192.168.2.20]
+`, `:5: target "192.168.2.20" must include the protocol`},
+		{"yaml client labels", "config.yaml", `
+clients:
+  labels: [ip, vendor]
+`, ":2: invalid labels"},
+		{"toml syntax", "config.toml", `
+log_level = "info"
+[controller
+host = "https://omada"
+`, ":3: expected '.' or ']'"},
+		{"toml unknown field", "config.toml", `
+log_level = "info"
+
+[controller]
+hots = "https://omada"
+`, `:4: unknown field "controller.hots"`},
+		{"toml invalid value", "config.toml", `
+[controller]
+host = "omada"
+`, `:2: host "omada" must include the protocol`},
+		{"toml module", "config.toml", `
+[modules.branch]
+auth_mode = "openapi"
+`, `:1: module "branch" requires a client_id and client_secret`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, tt.file, tt.content)
+			_, err := LoadFile(path)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.HasPrefix(err.Error(), path) || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected an error containing %q, got %q", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	for _, name := range []string{"config.yaml", "config.toml"} {
+		t.Run(name, func(t *testing.T) {
+			content := `
+log_level: debug
+sites:
+  include: [Default]
+controller:
+  host: https://omada
+`
+			if strings.HasSuffix(name, ".toml") {
+				content = `
+log_level = "debug"
+
+[sites]
+include = ["Default"]
+
+[controller]
+host = "https://omada"
+`
+			}
+
+			file, err := LoadFile(writeFile(t, name, content))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if file.LogLevel != "debug" || file.Controller.Host != "https://omada" || len(file.Sites.Include) != 1 {
+				t.Errorf("unexpected file %+v", file)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package forward
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/charlie-haley/omada_exporter/pkg/api"
+	log "github.com/rs/zerolog/log"
+)
+
+// Event is a site alert or event log entry as it's delivered to a sink
+type Event struct {
+	Site    string    `json:"site"`
+	SiteId  string    `json:"site_id"`
+	Type    string    `json:"type"`
+	Id      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Key     string    `json:"key"`
+	Level   string    `json:"level"`
+	Module  string    `json:"module"`
+	Content string    `json:"content"`
+}
+
+// Forwarder tails the alert and event logs of the client's sites on an interval and delivers new entries to a sink.
+// The position in each log is saved to the cursor file after every delivery, so entries aren't delivered
+// again after a restart. Entries are delivered at least once, a failed delivery is retried on the next poll.
+type Forwarder struct {
+	client     *api.Client
+	sink       Sink
+	interval   time.Duration
+	cursorFile string
+	cursors    map[string]api.LogCursor
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+func NewForwarder(client *api.Client, sink Sink, interval time.Duration, cursorFile string) (*Forwarder, error) {
+	cursors, err := loadCursors(cursorFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Forwarder{
+		client:     client,
+		sink:       sink,
+		interval:   interval,
+		cursorFile: cursorFile,
+		cursors:    cursors,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}, nil
+}
+
+// Start polls the controller in the background until closed
+func (f *Forwarder) Start() {
+	// the cursor file is read again as a previous forwarder may have saved it since, when the config is reloaded
+	cursors, err := loadCursors(f.cursorFile)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to reload cursor file")
+	} else {
+		f.cursors = cursors
+	}
+
+	go func() {
+		defer close(f.done)
+		ticker := time.NewTicker(f.interval)
+		defer ticker.Stop()
+		for {
+			f.poll()
+			select {
+			case <-ticker.C:
+			case <-f.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops polling, waiting for a poll in progress to finish
+func (f *Forwarder) Close() {
+	close(f.stop)
+	<-f.done
+}
+
+func (f *Forwarder) poll() {
+	for _, site := range f.client.Sites {
+		for _, kind := range []string{api.LogAlerts, api.LogEvents} {
+			err := f.forward(site, kind)
+			if err != nil {
+				log.Error().Err(err).Str("site", site.Name).Str("type", kind).Msg("Failed to forward logs")
+			}
+		}
+	}
+}
+
+func (f *Forwarder) forward(site api.Site, kind string) error {
+	key := site.Id + "/" + kind
+	cursor, ok := f.cursors[key]
+	if !ok {
+		// only entries logged after the first poll are forwarded, by the controller's clock
+		cursor = api.NewLogCursor(f.client.Now())
+		f.cursors[key] = cursor
+	}
+
+	entries, err := f.client.GetLogs(site.Id, kind, cursor.Start())
+	if err != nil {
+		return fmt.Errorf("failed to get %s: %s", kind, err)
+	}
+
+	entries, next := cursor.Next(entries)
+	if len(entries) > 0 {
+		events := []Event{}
+		for _, entry := range entries {
+			events = append(events, Event{
+				Site:    site.Name,
+				SiteId:  site.Id,
+				Type:    kind,
+				Id:      entry.Id,
+				Time:    time.UnixMilli(entry.Time),
+				Key:     entry.Key,
+				Level:   entry.Level,
+				Module:  entry.Module,
+				Content: entry.Content,
+			})
+		}
+		err = f.sink.Send(events)
+		if err != nil {
+			return fmt.Errorf("failed to send %d %s: %s", len(events), kind, err)
+		}
+		log.Debug().Int("count", len(events)).Str("site", site.Name).Str("type", kind).Msg("Forwarded logs")
+	}
+
+	f.cursors[key] = next
+	return saveCursors(f.cursorFile, f.cursors)
+}
+
+// loadCursors reads the cursors saved in the cursor file, keyed by site id and log type
+func loadCursors(path string) (map[string]api.LogCursor, error) {
+	cursors := map[string]api.LogCursor{}
+	if path == "" {
+		return cursors, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cursors, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cursor file: %s", err)
+	}
+
+	err = json.Unmarshal(data, &cursors)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cursor file %s: %s", path, err)
+	}
+	return cursors, nil
+}
+
+// saveCursors replaces the cursor file, writing to a temporary file first so a crash can't leave it half written
+func saveCursors(path string, cursors map[string]api.LogCursor) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(cursors)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write cursor file: %s", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write cursor file: %s", err)
+	}
+	return nil
+}
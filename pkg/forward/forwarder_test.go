@@ -0,0 +1,245 @@
+package forward
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/charlie-haley/omada_exporter/pkg/api"
+	"github.com/charlie-haley/omada_exporter/pkg/config"
+	"github.com/rs/zerolog"
+)
+
+func TestMain(m *testing.M) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	os.Exit(m.Run())
+}
+
+// fakeController serves the alert and event logs of a controller with a single site "Default"
+type fakeController struct {
+	*httptest.Server
+	mu   sync.Mutex
+	logs map[string][]api.LogEntry
+}
+
+func newFakeController(t *testing.T) *fakeController {
+	f := &fakeController{logs: map[string][]api.LogEntry{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeController) serve(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/cid")
+	w.Header().Set("Content-Type", "application/json")
+	switch path {
+	case "/api/info":
+		w.Write([]byte(`{"errorCode":0,"result":{"omadacId":"cid"}}`))
+	case "/api/v2/loginStatus":
+		w.Write([]byte(`{"errorCode":0,"result":{"login":true}}`))
+	case "/api/v2/login":
+		w.Write([]byte(`{"errorCode":0,"result":{"token":"token"}}`))
+	case "/api/v2/users/current":
+		w.Write([]byte(`{"errorCode":0,"result":{"privilege":{"sites":[{"name":"Default","key":"s1"}]}}}`))
+	case "/api/v2/sites/s1/alerts", "/api/v2/sites/s1/events":
+		f.mu.Lock()
+		entries := f.logs[strings.TrimPrefix(path, "/api/v2/sites/s1/")]
+		data, _ := json.Marshal(entries)
+		f.mu.Unlock()
+		fmt.Fprintf(w, `{"errorCode":0,"result":{"totalRows":%d,"data":%s}}`, len(entries), data)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// log adds entries to the logs of a kind, newest first as the controller returns them
+func (f *fakeController) log(kind string, entries ...api.LogEntry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.logs[kind] = append(entries, f.logs[kind]...)
+}
+
+func newTestClient(t *testing.T, f *fakeController) *api.Client {
+	client, err := api.Configure(&config.Config{
+		Host:     f.URL,
+		AuthMode: config.AuthModeWeb,
+		Username: "user",
+		Password: "password",
+		Site:     "Default",
+		Timeout:  5,
+	})
+	if err != nil {
+		t.Fatalf("failed to configure client: %s", err)
+	}
+	return client
+}
+
+// fakeSink records the ids of the events sent to it, failing while err is set
+type fakeSink struct {
+	ids []string
+	err error
+}
+
+func (s *fakeSink) Send(events []Event) error {
+	if s.err != nil {
+		return s.err
+	}
+	for _, e := range events {
+		s.ids = append(s.ids, e.Type+"/"+e.Id)
+	}
+	return nil
+}
+
+// writeCursors saves a cursor file which starts every log of the site at the epoch
+func writeCursors(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "cursors.json")
+	err := saveCursors(path, map[string]api.LogCursor{
+		"s1/alerts": {Time: 0, Ids: map[string]bool{}},
+		"s1/events": {Time: 0, Ids: map[string]bool{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestForwarderCursorFile(t *testing.T) {
+	f := newFakeController(t)
+	f.log(api.LogEvents, api.LogEntry{Id: "e2", Time: 2000}, api.LogEntry{Id: "e1", Time: 1000})
+	f.log(api.LogAlerts, api.LogEntry{Id: "a2", Time: 3000}, api.LogEntry{Id: "a1", Time: 3000})
+	path := writeCursors(t)
+
+	forwarder, err := NewForwarder(newTestClient(t, f), &fakeSink{}, 0, path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	forwarder.poll()
+
+	cursors, err := loadCursors(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := map[string]api.LogCursor{
+		"s1/alerts": {Time: 3000, Ids: map[string]bool{"a1": true, "a2": true}},
+		"s1/events": {Time: 2000, Ids: map[string]bool{"e2": true}},
+	}
+	for key, cursor := range expected {
+		got := cursors[key]
+		if got.Time != cursor.Time || fmt.Sprint(got.Ids) != fmt.Sprint(cursor.Ids) {
+			t.Errorf("expected %v for %s, got %v", cursor, key, got)
+		}
+	}
+}
+
+func TestForwarderRestart(t *testing.T) {
+	tests := []struct {
+		name   string
+		logged []api.LogEntry
+		sent   string
+	}{
+		{"nothing new", nil, ""},
+		{"new entry", []api.LogEntry{{Id: "e3", Time: 3000}}, "events/e3"},
+		{"new entry at the same time", []api.LogEntry{{Id: "e3", Time: 2000}}, "events/e3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeController(t)
+			f.log(api.LogEvents, api.LogEntry{Id: "e2", Time: 2000}, api.LogEntry{Id: "e1", Time: 1000})
+			path := writeCursors(t)
+			client := newTestClient(t, f)
+
+			sink := &fakeSink{}
+			forwarder, err := NewForwarder(client, sink, 0, path)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			forwarder.poll()
+			if strings.Join(sink.ids, ",") != "events/e1,events/e2" {
+				t.Errorf("expected both entries to be sent, got %v", sink.ids)
+			}
+
+			// a new forwarder reads where the previous one got to from the cursor file
+			f.log(api.LogEvents, tt.logged...)
+			sink = &fakeSink{}
+			forwarder, err = NewForwarder(client, sink, 0, path)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			forwarder.poll()
+			if strings.Join(sink.ids, ",") != tt.sent {
+				t.Errorf("expected %q to be sent after restarting, got %v", tt.sent, sink.ids)
+			}
+		})
+	}
+}
+
+func TestForwarderRetriesFailedDelivery(t *testing.T) {
+	f := newFakeController(t)
+	f.log(api.LogEvents, api.LogEntry{Id: "e1", Time: 1000})
+	path := writeCursors(t)
+
+	sink := &fakeSink{err: fmt.Errorf("unavailable")}
+	forwarder, err := NewForwarder(newTestClient(t, f), sink, 0, path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	site := forwarder.client.Sites[0]
+	if err := forwarder.forward(site, api.LogEvents); err == nil {
+		t.Error("expected an error when the sink fails")
+	}
+	cursors, err := loadCursors(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cursors["s1/events"].Time != 0 {
+		t.Errorf("expected the cursor not to move after a failed delivery, got %v", cursors["s1/events"])
+	}
+
+	sink.err = nil
+	if err := forwarder.forward(site, api.LogEvents); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if strings.Join(sink.ids, ",") != "events/e1" {
+		t.Errorf("expected the entry to be sent on the next poll, got %v", sink.ids)
+	}
+}
+
+func TestLoadCursors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		cursors int
+		err     bool
+	}{
+		{"missing", "", 0, false},
+		{"saved", `{"s1/events":{"time":1000,"ids":{"e1":true}}}`, 1, false},
+		{"corrupt", `{"s1/events":`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cursors.json")
+			if tt.content != "" {
+				err := os.WriteFile(path, []byte(tt.content), 0o600)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cursors, err := loadCursors(path)
+			if (err != nil) != tt.err {
+				t.Fatalf("expected error %t, got %v", tt.err, err)
+			}
+			if len(cursors) != tt.cursors {
+				t.Errorf("expected %d cursors, got %d", tt.cursors, len(cursors))
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package forward
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/charlie-haley/omada_exporter/pkg/config"
+	"github.com/rs/zerolog"
+)
+
+// Sink delivers forwarded events
+type Sink interface {
+	Send(events []Event) error
+}
+
+// NewSink returns the sink configured by name, url is the webhook or Loki push API to send to
+func NewSink(name string, url string, timeout time.Duration) (Sink, error) {
+	httpClient := &http.Client{Timeout: timeout}
+	switch name {
+	case config.ForwardSinkWebhook:
+		return &webhookSink{url: url, httpClient: httpClient}, nil
+	case config.ForwardSinkStdout:
+		return &stdoutSink{logger: zerolog.New(os.Stdout)}, nil
+	case config.ForwardSinkLoki:
+		return &lokiSink{url: url, httpClient: httpClient}, nil
+	}
+	return nil, fmt.Errorf("unknown sink %q", name)
+}
+
+// webhookSink posts the events to a URL as a JSON array
+type webhookSink struct {
+	url        string
+	httpClient *http.Client
+}
+
+func (s *webhookSink) Send(events []Event) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	return post(s.httpClient, s.url, body)
+}
+
+// stdoutSink writes each event to stdout as a zerolog line, regardless of the log level
+type stdoutSink struct {
+	logger zerolog.Logger
+}
+
+func (s *stdoutSink) Send(events []Event) error {
+	for _, e := range events {
+		s.logger.Log().
+			Time("time", e.Time).
+			Str("site", e.Site).
+			Str("site_id", e.SiteId).
+			Str("type", e.Type).
+			Str("id", e.Id).
+			Str("key", e.Key).
+			Str("severity", e.Level).
+			Str("module", e.Module).
+			Msg(e.Content)
+	}
+	return nil
+}
+
+// lokiSink pushes the events to Loki, with a stream per site, type and severity
+type lokiSink struct {
+	url        string
+	httpClient *http.Client
+}
+
+type lokiPush struct {
+	Streams []*lokiStream `json:"streams"`
+}
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func (s *lokiSink) Send(events []Event) error {
+	push := lokiPush{}
+	streams := map[string]*lokiStream{}
+	for _, e := range events {
+		key := e.SiteId + "|" + e.Type + "|" + e.Level
+		stream, ok := streams[key]
+		if !ok {
+			stream = &lokiStream{Stream: map[string]string{
+				"job":      "omada_exporter",
+				"site":     e.Site,
+				"type":     e.Type,
+				"severity": e.Level,
+			}}
+			streams[key] = stream
+			push.Streams = append(push.Streams, stream)
+		}
+
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		stream.Values = append(stream.Values, [2]string{strconv.FormatInt(e.Time.UnixNano(), 10), string(line)})
+	}
+
+	body, err := json.Marshal(push)
+	if err != nil {
+		return err
+	}
+	return post(s.httpClient, s.url, body)
+}
+
+func post(httpClient *http.Client, url string, body []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "omada_exporter")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s responded with %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package forward
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/charlie-haley/omada_exporter/pkg/config"
+)
+
+var testEvents = []Event{
+	{Site: "Default", SiteId: "s1", Type: "alerts", Id: "a1", Time: time.UnixMilli(1000), Level: "Warning", Content: "AP disconnected"},
+	{Site: "Default", SiteId: "s1", Type: "events", Id: "e1", Time: time.UnixMilli(2000), Level: "Info", Content: "Client connected"},
+	{Site: "Default", SiteId: "s1", Type: "events", Id: "e2", Time: time.UnixMilli(3000), Level: "Info", Content: "Client disconnected"},
+}
+
+// sinkServer responds to every request with status, recording the last request's body and content type
+func sinkServer(t *testing.T, status int, body *[]byte, contentType *string) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*body, _ = io.ReadAll(r.Body)
+		*contentType = r.Header.Get("Content-Type")
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestWebhookSink(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		err    bool
+	}{
+		{"ok", http.StatusOK, false},
+		{"no content", http.StatusNoContent, false},
+		{"not found", http.StatusNotFound, true},
+		{"server error", http.StatusInternalServerError, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body []byte
+			var contentType string
+			sink, err := NewSink(config.ForwardSinkWebhook, sinkServer(t, tt.status, &body, &contentType), 5*time.Second)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			err = sink.Send(testEvents)
+			if (err != nil) != tt.err {
+				t.Errorf("expected error %t, got %v", tt.err, err)
+			}
+			if contentType != "application/json" {
+				t.Errorf("expected a JSON body, got %s", contentType)
+			}
+			events := []Event{}
+			err = json.Unmarshal(body, &events)
+			if err != nil {
+				t.Fatalf("failed to parse body: %s", err)
+			}
+			if len(events) != len(testEvents) || events[0].Id != "a1" || !events[2].Time.Equal(testEvents[2].Time) {
+				t.Errorf("expected the events to be posted as a JSON array, got %s", body)
+			}
+		})
+	}
+}
+
+func TestWebhookSinkUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	sink, err := NewSink(config.ForwardSinkWebhook, server.URL, 5*time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := sink.Send(testEvents); err == nil {
+		t.Error("expected an error when the webhook can't be reached")
+	}
+}
+
+func TestLokiSink(t *testing.T) {
+	var body []byte
+	var contentType string
+	sink, err := NewSink(config.ForwardSinkLoki, sinkServer(t, http.StatusNoContent, &body, &contentType), 5*time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = sink.Send(testEvents)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	push := lokiPush{}
+	err = json.Unmarshal(body, &push)
+	if err != nil {
+		t.Fatalf("failed to parse push: %s", err)
+	}
+	expected := []struct {
+		labels map[string]string
+		values []string
+	}{
+		{map[string]string{"job": "omada_exporter", "site": "Default", "type": "alerts", "severity": "Warning"}, []string{"1000000000"}},
+		{map[string]string{"job": "omada_exporter", "site": "Default", "type": "events", "severity": "Info"}, []string{"2000000000", "3000000000"}},
+	}
+	if len(push.Streams) != len(expected) {
+		t.Fatalf("expected %d streams, got %s", len(expected), body)
+	}
+	for i, stream := range push.Streams {
+		for k, v := range expected[i].labels {
+			if stream.Stream[k] != v {
+				t.Errorf("expected stream %d label %s=%s, got %v", i, k, v, stream.Stream)
+			}
+		}
+		if len(stream.Values) != len(expected[i].values) {
+			t.Fatalf("expected %d values in stream %d, got %v", len(expected[i].values), i, stream.Values)
+		}
+		for j, value := range stream.Values {
+			if value[0] != expected[i].values[j] {
+				t.Errorf("expected timestamp %s, got %s", expected[i].values[j], value[0])
+			}
+			line := Event{}
+			err = json.Unmarshal([]byte(value[1]), &line)
+			if err != nil || line.SiteId != "s1" || line.Content == "" {
+				t.Errorf("expected the event as a JSON line, got %s", value[1])
+			}
+		}
+	}
+}
+
+func TestNewSinkUnknown(t *testing.T) {
+	if _, err := NewSink("syslog", "", time.Second); err == nil {
+		t.Error("expected an error for an unknown sink")
+	}
+}